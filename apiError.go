@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// =====================================================
+// ===== apiError.go | Errors from the Spotify API =====
+// =====================================================
+
+// Reasons the player endpoints give for refusing a command.
+const (
+	REASON_NO_ACTIVE_DEVICE  = "NO_ACTIVE_DEVICE"
+	REASON_PREMIUM_REQUIRED  = "PREMIUM_REQUIRED"
+	REASON_NO_NEXT_TRACK     = "NO_NEXT_TRACK"
+	REASON_NO_PREV_TRACK     = "NO_PREV_TRACK"
+	REASON_ALREADY_PAUSED    = "ALREADY_PAUSED"
+	REASON_ALREADY_PLAYING   = "ALREADY_PLAYING"
+	REASON_NOT_CONTROLLABLE  = "DEVICE_NOT_CONTROLLABLE"
+	REASON_REMOTE_DISALLOWED = "REMOTE_CONTROL_DISALLOW"
+	REASON_RATE_LIMITED      = "RATE_LIMITED"
+)
+
+// Wording for the reasons worth explaining to the user; the rest use Spotify's message.
+var reasonText = map[string]string{
+	REASON_NO_ACTIVE_DEVICE:  "no active device, start playing in a Spotify app",
+	REASON_PREMIUM_REQUIRED:  "Spotify Premium is needed to control playback",
+	REASON_NO_NEXT_TRACK:     "nothing to skip to",
+	REASON_NO_PREV_TRACK:     "nothing to go back to",
+	REASON_ALREADY_PAUSED:    "already paused",
+	REASON_ALREADY_PLAYING:   "already playing",
+	REASON_NOT_CONTROLLABLE:  "this device can't be controlled remotely",
+	REASON_REMOTE_DISALLOWED: "this device can't be controlled remotely",
+	REASON_RATE_LIMITED:      "rate limited by Spotify",
+}
+
+// APIError is an error status from the Spotify API, with the details from its error body if it had one.
+type APIError struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+	Reason  string `json:"reason"` // Only set by the player endpoints
+}
+
+func (e *APIError) Error() string {
+	if text, ok := reasonText[e.Reason]; ok {
+		return text
+	}
+	if e.Message != "" {
+		return fmt.Sprintf("%s (%d)", e.Message, e.Status)
+	}
+	return fmt.Sprintf("%s (%d)", http.StatusText(e.Status), e.Status)
+}
+
+// newAPIError reads a failed response's body of the form {"error":{"status","message","reason"}}.
+// Bodies that don't parse still give an APIError, with just the status.
+func newAPIError(resp *http.Response) *APIError {
+	var body struct {
+		Error APIError `json:"error"`
+	}
+	if data, err := io.ReadAll(resp.Body); err == nil {
+		json.Unmarshal(data, &body)
+	}
+	apiErr := body.Error
+	apiErr.Status = resp.StatusCode // The body's copy is sometimes missing
+	return &apiErr
+}
+
+// apiStatus gets the HTTP status of a Spotify API error, 0 for any other error.
+func apiStatus(err error) int {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Status
+	}
+	return 0
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ======================================================================
+// ===== artist.go | Artist pages: top tracks, discography, related =====
+// ======================================================================
+
+const (
+	ARTIST_TOP_TRACKS = 5 // Top tracks shown above the discography
+	ARTIST_RELATED    = 5 // Related artists shown below it
+	ARTIST_HEADINGS   = 4 // Lines taken by the artist header and the section headings
+)
+
+// ArtistPage is an artist view, shown in place of the library.
+// It is replaced rather than changed, since models are copied around.
+type ArtistPage struct {
+	Artist    SpotifyArtist
+	Following bool
+	TopTracks []SpotifyTrack
+	Albums    []SpotifyArtistAlbum // The current page of the discography
+	Related   []SpotifyArtist
+	pager     Paginator
+
+	album  *SpotifyArtistAlbum // The album drilled into, if any
+	tracks []SpotifyTrack      // Its tracks, once loaded
+	parent *ArtistPage         // The page to go back to, if this one was opened from another artist
+}
+
+// artistLoadedMsg is a freshly opened artist page.
+type artistLoadedMsg struct {
+	page *ArtistPage
+	err  error
+}
+
+// artistAlbumsMsg is a page of an artist's discography.
+type artistAlbumsMsg struct {
+	artistID string
+	albums   []SpotifyArtistAlbum
+	total    int
+	err      error
+}
+
+// albumTracksMsg is the track list of an album drilled into from an artist page.
+type albumTracksMsg struct {
+	albumURI string
+	tracks   []SpotifyTrack
+	err      error
+}
+
+// artistFollowMsg reports the result of following or unfollowing an artist.
+type artistFollowMsg struct {
+	artistID  string
+	name      string
+	following bool
+	err       error
+}
+
+// resolveArtistURI finds the main artist of anything with one: an artist, album or track.
+func resolveArtistURI(ctx context.Context, token, uri string) (string, error) {
+	type withArtists struct {
+		Artists []struct {
+			URI string `json:"uri"`
+		} `json:"artists"`
+	}
+	var item withArtists
+	var err error
+	switch uriKind(uri) {
+	case "artist":
+		return uri, nil
+	case "album", "track":
+		item, err = genericFetch[withArtists](ctx, "/"+uriKind(uri)+"s/"+uriID(uri), token, nil, nil)
+	default:
+		return "", fmt.Errorf("no artist page for %ss", uriKind(uri))
+	}
+	if err != nil {
+		return "", err
+	}
+	if len(item.Artists) == 0 || item.Artists[0].URI == "" {
+		return "", fmt.Errorf("no artist found for %s", uri)
+	}
+	return item.Artists[0].URI, nil
+}
+
+// openArtistCmd returns a command that loads the artist page for anything with an artist.
+//
+// Parameters:
+// - ctx: Cancels the requests, when another library fetch replaces this one.
+// - token: Spotify access token.
+// - uri: An artist, album or track URI.
+// - pager: Paginator for the discography, sized for the library pane.
+// - parent: The artist page this one was opened from, or nil.
+//
+// Returns:
+// - A command returning artistLoadedMsg.
+func openArtistCmd(ctx context.Context, token, uri string, pager Paginator, parent *ArtistPage) tea.Cmd {
+	return func() tea.Msg {
+		artistURI, err := resolveArtistURI(ctx, token, uri)
+		if err != nil {
+			return artistLoadedMsg{err: err}
+		}
+		id := uriID(artistURI)
+		artist, err := genericFetch[SpotifyArtist](ctx, "/artists/"+id, token, nil, nil)
+		if err != nil {
+			return artistLoadedMsg{err: err}
+		}
+
+		page := &ArtistPage{Artist: artist, pager: pager, parent: parent}
+		top, err := handleGenericFetch[struct {
+			Tracks []SpotifyTrack `json:"tracks"`
+		}](ctx, "/artists/"+id+"/top-tracks", token, map[string]string{"market": "from_token"}, nil)
+		if err != nil {
+			return artistLoadedMsg{err: err}
+		}
+		page.TopTracks = top.Tracks[:min(len(top.Tracks), ARTIST_TOP_TRACKS)]
+
+		albums := fetchArtistAlbums(ctx, token, id, pager)
+		if albums.err != nil {
+			return artistLoadedMsg{err: albums.err}
+		}
+		page.Albums = albums.albums
+		page.pager = pager.WithTotal(albums.total)
+
+		following, err := handleGenericFetch[[]bool](ctx, "/me/following/contains", token, map[string]string{"type": "artist", "ids": id}, nil)
+		if err != nil {
+			return artistLoadedMsg{err: err}
+		}
+		page.Following = len(following) > 0 && following[0]
+
+		// Related artists are no longer available to every app, so an empty list is fine
+		related, _ := handleGenericFetch[struct {
+			Artists []SpotifyArtist `json:"artists"`
+		}](ctx, "/artists/"+id+"/related-artists", token, nil, nil)
+		page.Related = related.Artists[:min(len(related.Artists), ARTIST_RELATED)]
+		return artistLoadedMsg{page: page}
+	}
+}
+
+// fetchArtistAlbums fetches one page of an artist's albums, singles and compilations.
+func fetchArtistAlbums(ctx context.Context, token, id string, pager Paginator) artistAlbumsMsg {
+	albums, err := handleGenericFetch[SpotifyPage[SpotifyArtistAlbum]](ctx, "/artists/"+id+"/albums", token, map[string]string{
+		"include_groups": "album,single,compilation",
+		"limit":          fmt.Sprintf("%d", min(pager.PageSize(), 50)),
+		"offset":         fmt.Sprintf("%d", pager.Offset()),
+	}, nil)
+	return artistAlbumsMsg{artistID: id, albums: albums.Items, total: albums.Total, err: err}
+}
+
+// artistAlbumsCmd returns a command that fetches another page of the discography.
+func artistAlbumsCmd(ctx context.Context, token, id string, pager Paginator) tea.Cmd {
+	return func() tea.Msg {
+		return fetchArtistAlbums(ctx, token, id, pager)
+	}
+}
+
+// albumTracksCmd returns a command that fetches every track of an album.
+func albumTracksCmd(ctx context.Context, token string, album SpotifyArtistAlbum) tea.Cmd {
+	return func() tea.Msg {
+		tracks, err := handleFetchAll[SpotifyTrack](ctx, "/albums/"+album.ID+"/tracks", token)
+		return albumTracksMsg{albumURI: album.URI, tracks: tracks, err: err}
+	}
+}
+
+// followCmd returns a command that follows or unfollows an artist.
+func followCmd(ctx context.Context, token string, artist SpotifyArtist, follow bool) tea.Cmd {
+	id := artist.ID
+	return func() tea.Msg {
+		params := map[string]string{"type": "artist", "ids": id}
+		var err error
+		if follow {
+			_, err = genericPut(ctx, "/me/following", token, params, nil)
+		} else {
+			_, err = genericDelete(ctx, "/me/following", token, params, nil)
+		}
+		return artistFollowMsg{artistID: id, name: artist.Name, following: follow, err: err}
+	}
+}
+
+// rows gets the library rows of an artist page: the artist itself (Enter plays it), top tracks,
+// the current page of the discography and related artists. Inside an album, its tracks.
+func (p *ArtistPage) rows(favorites *FavoritesStore) []LibraryItem {
+	rows := []LibraryItem{}
+	add := func(item LibraryItem) {
+		item.favorite = favorites.Contains(item.uri)
+		rows = append(rows, item)
+	}
+	if p.album != nil {
+		for _, track := range p.tracks {
+			add(LibraryItem{name: displayTrack(track).Title, artist: msToMinSec(track.DurationMs), uri: track.URI})
+		}
+		return rows
+	}
+	add(LibraryItem{name: p.Artist.Name, artist: "Play artist", uri: p.Artist.URI})
+	for _, track := range p.TopTracks {
+		display := displayTrack(track)
+		add(LibraryItem{name: display.Title, artist: display.Subtitle, uri: display.URI})
+	}
+	for _, album := range p.Albums {
+		add(LibraryItem{name: album.Name, artist: releaseYear(album.ReleaseDate) + " " + album.AlbumGroup, uri: album.URI})
+	}
+	for _, artist := range p.Related {
+		add(LibraryItem{name: artist.Name, artist: "Artist", uri: artist.URI})
+	}
+	return rows
+}
+
+// section gets the heading a row falls under, so the view can print headings between sections.
+func (p *ArtistPage) section(row int) string {
+	switch {
+	case p.album != nil || row == 0:
+		return ""
+	case row <= len(p.TopTracks):
+		return "Top tracks"
+	case row <= len(p.TopTracks)+len(p.Albums):
+		return fmt.Sprintf("Discography (page %d of %d)", p.pager.Page()+1, p.pager.Pages())
+	default:
+		return "Related artists"
+	}
+}
+
+// header describes the artist, or the album drilled into.
+func (p *ArtistPage) header() string {
+	if p.album != nil {
+		return fmt.Sprintf("%s › %s (%s)", p.Artist.Name, p.album.Name, releaseYear(p.album.ReleaseDate))
+	}
+	header := fmt.Sprintf("%s  %d followers", p.Artist.Name, p.Artist.Followers.Total)
+	if p.Following {
+		header += "  Following"
+	}
+	if len(p.Artist.Genres) > 0 {
+		header += "  " + strings.Join(p.Artist.Genres[:min(len(p.Artist.Genres), 3)], ", ")
+	}
+	return header
+}
+
+// releaseYear gets the year of a Spotify release date, which may be just a year.
+func releaseYear(date string) string {
+	if len(date) >= 4 {
+		return date[:4]
+	}
+	return date
+}
+
+// artistPager gets the discography paginator, sized to what's left of the library pane after the other sections.
+func (m Model) artistPager() Paginator {
+	return Paginator{}.Resize(m.height-LIBRARY_SPACING, 1+ARTIST_TOP_TRACKS+ARTIST_RELATED+ARTIST_HEADINGS)
+}
+
+// openArtist opens the artist page for a URI, from the library or from another artist page.
+func (m Model) openArtist(uri string, parent *ArtistPage) (Model, tea.Cmd) {
+	if uri == "" {
+		return m, nil
+	}
+	m.loading = true
+	return m.fetchLibrary(func(ctx context.Context) tea.Cmd {
+		return openArtistCmd(ctx, m.token, uri, m.artistPager(), parent)
+	})
+}
+
+// showArtist puts an artist page (or nil, for the library) in the library pane.
+func (m Model) showArtist(page *ArtistPage) (Model, tea.Cmd) {
+	m.artist = page
+	m.cursor = 0
+	if page == nil {
+		m.libraryList = nil
+		return m.reloadLibrary()
+	}
+	m.libraryList = page.rows(m.favoriteStore)
+	return m, nil
+}
+
+// selectArtistRow acts on the row under the cursor of an artist page: play the artist or a track,
+// drill into an album, or open a related artist.
+func (m Model) selectArtistRow() (Model, tea.Cmd) {
+	if len(m.libraryList) == 0 {
+		return m, nil
+	}
+	uri := m.libraryList[m.cursor].uri
+	if m.artist.album != nil {
+		if m.state.IsPlaying {
+			contextURI := m.artist.album.URI
+			return m.startPlay("play", func(ctx context.Context, token, deviceID string) error {
+				return handlePlayInContext(ctx, token, deviceID, contextURI, uri)
+			})
+		}
+		return m, nil
+	}
+	switch uriKind(uri) {
+	case "album":
+		for _, album := range m.artist.Albums {
+			if album.URI == uri {
+				page := *m.artist
+				page.album, page.tracks, page.parent = &album, nil, m.artist
+				m.loading = true
+				model, _ := m.showArtist(&page)
+				return model.fetchLibrary(func(ctx context.Context) tea.Cmd {
+					return albumTracksCmd(ctx, m.token, album)
+				})
+			}
+		}
+	case "artist":
+		if uri != m.artist.Artist.URI {
+			return m.openArtist(uri, m.artist)
+		}
+	}
+	if m.state.IsPlaying {
+		return m.startPlay("play", func(ctx context.Context, token, deviceID string) error {
+			return handlePlayURI(ctx, token, deviceID, uri)
+		})
+	}
+	return m, nil
+}
+
+// pageArtist moves the discography of the open artist page to another page.
+func (m Model) pageArtist(pager Paginator) (Model, tea.Cmd) {
+	if m.artist.album != nil {
+		return m, nil
+	}
+	page := *m.artist
+	page.pager = pager
+	m.artist = &page
+	return m.fetchLibrary(func(ctx context.Context) tea.Cmd {
+		return artistAlbumsCmd(ctx, m.token, page.Artist.ID, pager)
+	})
+}
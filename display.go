@@ -0,0 +1,145 @@
+package main
+
+import (
+	"strings"
+)
+
+// ================================================================
+// ===== display.go | One safe shape for everything we render =====
+// ================================================================
+
+// Placeholders for fields the API left out, e.g. local files without tags.
+const (
+	UNKNOWN_TITLE  = "Unknown title"
+	UNKNOWN_ARTIST = "Unknown artist"
+)
+
+// DisplayItem is what the renderers need to show anything playable or browsable.
+// It is built defensively from each API type, so renderers never index into API slices themselves.
+type DisplayItem struct {
+	Title      string
+	Subtitle   string   // The album of a track, the publisher of an episode or show
+	Artists    []string // The artists of a track or album, the show of an episode, the owner of a playlist
+	DurationMs int
+	Image      string // Largest artwork, or "" if there is none
+	Kind       string // track, episode, album, playlist, show or artist
+	IsLocal    bool   // A local file, which has no artwork or artist links
+	URI        string
+}
+
+// Artist gets the artists joined for display, or a placeholder.
+func (d DisplayItem) Artist() string {
+	if len(d.Artists) == 0 {
+		return UNKNOWN_ARTIST
+	}
+	return strings.Join(d.Artists, ", ")
+}
+
+// FirstArtist gets the main artist, or a placeholder.
+func (d DisplayItem) FirstArtist() string {
+	if len(d.Artists) == 0 {
+		return UNKNOWN_ARTIST
+	}
+	return d.Artists[0]
+}
+
+// libraryItem turns a display item into a library row.
+func (d DisplayItem) libraryItem() LibraryItem {
+	return LibraryItem{name: d.Title, artist: d.FirstArtist(), uri: d.URI}
+}
+
+// newDisplayItem fills in what every constructor shares: placeholders and the kind.
+func newDisplayItem(d DisplayItem) DisplayItem {
+	if strings.TrimSpace(d.Title) == "" {
+		d.Title = UNKNOWN_TITLE
+	}
+	artists := d.Artists[:0:0]
+	for _, artist := range d.Artists {
+		if strings.TrimSpace(artist) != "" {
+			artists = append(artists, artist)
+		}
+	}
+	d.Artists = artists
+	if d.Kind == "" {
+		d.Kind = uriKind(d.URI)
+	}
+	return d
+}
+
+// firstImage gets the first (largest) image URL of an API image list.
+func firstImage(images []struct {
+	URL string `json:"url"`
+}) string {
+	if len(images) == 0 {
+		return ""
+	}
+	return images[0].URL
+}
+
+// display gets the display item for whatever is playing.
+func (s PlaybackState) display() DisplayItem {
+	return s.Item.display()
+}
+
+// display gets the display item for a track, local file or episode, playing or queued.
+func (item PlayableItem) display() DisplayItem {
+	d := DisplayItem{Title: item.Name, DurationMs: item.DurationMs, URI: item.URI, IsLocal: item.IsLocal, Kind: item.Type}
+	if item.Type == "episode" {
+		d.Subtitle = item.Show.Publisher
+		if item.Show.Name != "" {
+			d.Artists = []string{item.Show.Name}
+		}
+		d.Image = firstImage(item.Images)
+		if d.Image == "" {
+			d.Image = firstImage(item.Show.Images)
+		}
+		return newDisplayItem(d)
+	}
+	d.Subtitle = item.Album.Name
+	for _, artist := range item.Artists {
+		d.Artists = append(d.Artists, artist.Name)
+	}
+	d.Image = firstImage(item.Album.Images)
+	return newDisplayItem(d)
+}
+
+// displayTrack gets the display item for a track from a playlist, album or top tracks list.
+func displayTrack(t SpotifyTrack) DisplayItem {
+	d := DisplayItem{Title: t.Name, Subtitle: t.Album.Name, DurationMs: t.DurationMs, URI: t.URI, IsLocal: t.IsLocal, Kind: "track"}
+	for _, artist := range t.Artists {
+		d.Artists = append(d.Artists, artist.Name)
+	}
+	return newDisplayItem(d)
+}
+
+// displayAlbum gets the display item for a saved album.
+func displayAlbum(a SpotifyAlbumItem) DisplayItem {
+	d := DisplayItem{Title: a.Album.Name, URI: a.Album.URI, Kind: "album"}
+	for _, artist := range a.Album.Artists {
+		d.Artists = append(d.Artists, artist.Name)
+	}
+	return newDisplayItem(d)
+}
+
+// displayPlaylist gets the display item for a playlist. Its owner stands in for the artist.
+func displayPlaylist(p SpotifyPlaylistItem) DisplayItem {
+	d := DisplayItem{Title: p.Name, URI: p.URI, Kind: "playlist"}
+	if p.Owner.DisplayName != "" {
+		d.Artists = []string{p.Owner.DisplayName}
+	}
+	return newDisplayItem(d)
+}
+
+// displayShow gets the display item for a saved show. Its publisher stands in for the artist.
+func displayShow(s SpotifyShow) DisplayItem {
+	d := DisplayItem{Title: s.Name, Subtitle: s.Publisher, URI: s.URI, Kind: "show"}
+	if s.Publisher != "" {
+		d.Artists = []string{s.Publisher}
+	}
+	return newDisplayItem(d)
+}
+
+// displayEpisode gets the display item for an episode in a show's episode list.
+func displayEpisode(e SpotifyEpisode) DisplayItem {
+	return newDisplayItem(DisplayItem{Title: e.Name, DurationMs: e.DurationMs, URI: e.URI, Kind: "episode"})
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+// Playable items as the player endpoints return them, trimmed to the fields we read.
+const (
+	localTrackFixture = `{
+		"type": "track", "uri": "spotify:local:Some+Band:Demos:Track+One:215", "name": "Track One",
+		"duration_ms": 215000, "is_local": true,
+		"album": {"name": "Demos", "images": []},
+		"artists": [{"name": "Some Band", "id": null, "uri": null}]
+	}`
+	untaggedLocalFixture = `{
+		"type": "track", "uri": "spotify:local:::track01:180", "name": "",
+		"duration_ms": 180000, "is_local": true,
+		"album": {"name": "", "images": []},
+		"artists": [{"name": ""}]
+	}`
+	episodeFixture = `{
+		"type": "episode", "uri": "spotify:episode:abc", "name": "Episode 12",
+		"duration_ms": 3600000,
+		"images": [{"url": "https://i.scdn.co/episode.jpg"}, {"url": "https://i.scdn.co/episode-small.jpg"}],
+		"show": {"name": "The Show", "publisher": "Some Network", "uri": "spotify:show:def", "images": [{"url": "https://i.scdn.co/show.jpg"}]}
+	}`
+	episodeWithoutArtFixture = `{
+		"type": "episode", "uri": "spotify:episode:ghi", "name": "Episode 13",
+		"images": [],
+		"show": {"name": "", "publisher": "", "images": [{"url": "https://i.scdn.co/show.jpg"}]}
+	}`
+	bareTrackFixture = `{"type": "track", "uri": "spotify:track:xyz", "name": "Lonely Track"}`
+)
+
+func TestPlayableItemDisplay(t *testing.T) {
+	tests := []struct {
+		name         string
+		fixture      string
+		wantTitle    string
+		wantSubtitle string
+		wantArtists  []string
+		wantArtist   string
+		wantImage    string
+		wantKind     string
+		wantLocal    bool
+	}{
+		{
+			name: "local track", fixture: localTrackFixture,
+			wantTitle: "Track One", wantSubtitle: "Demos", wantArtists: []string{"Some Band"}, wantArtist: "Some Band",
+			wantKind: "track", wantLocal: true,
+		},
+		{
+			name: "untagged local track", fixture: untaggedLocalFixture,
+			wantTitle: UNKNOWN_TITLE, wantArtists: []string{}, wantArtist: UNKNOWN_ARTIST,
+			wantKind: "track", wantLocal: true,
+		},
+		{
+			name: "episode", fixture: episodeFixture,
+			wantTitle: "Episode 12", wantSubtitle: "Some Network", wantArtists: []string{"The Show"}, wantArtist: "The Show",
+			wantImage: "https://i.scdn.co/episode.jpg", wantKind: "episode",
+		},
+		{
+			name: "episode falls back to show art", fixture: episodeWithoutArtFixture,
+			wantTitle: "Episode 13", wantArtists: []string{}, wantArtist: UNKNOWN_ARTIST,
+			wantImage: "https://i.scdn.co/show.jpg", wantKind: "episode",
+		},
+		{
+			name: "track without artists, album or images", fixture: bareTrackFixture,
+			wantTitle: "Lonely Track", wantArtists: []string{}, wantArtist: UNKNOWN_ARTIST, wantKind: "track",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var item PlayableItem
+			if err := json.Unmarshal([]byte(tt.fixture), &item); err != nil {
+				t.Fatalf("bad fixture: %v", err)
+			}
+			d := item.display()
+			if d.Title != tt.wantTitle || d.Subtitle != tt.wantSubtitle {
+				t.Errorf("title %q, subtitle %q, want %q, %q", d.Title, d.Subtitle, tt.wantTitle, tt.wantSubtitle)
+			}
+			if !slices.Equal(d.Artists, tt.wantArtists) || d.Artist() != tt.wantArtist || d.FirstArtist() != tt.wantArtist {
+				t.Errorf("artists %q (%q), want %q (%q)", d.Artists, d.Artist(), tt.wantArtists, tt.wantArtist)
+			}
+			if d.Image != tt.wantImage {
+				t.Errorf("image %q, want %q", d.Image, tt.wantImage)
+			}
+			if d.Kind != tt.wantKind || d.IsLocal != tt.wantLocal {
+				t.Errorf("kind %q, local %v, want %q, %v", d.Kind, d.IsLocal, tt.wantKind, tt.wantLocal)
+			}
+		})
+	}
+}
+
+func TestEmptyPlaybackDisplay(t *testing.T) {
+	d := PlaybackState{}.display()
+	if d.Title != UNKNOWN_TITLE || d.Artist() != UNKNOWN_ARTIST || d.Image != "" {
+		t.Errorf("got %+v, want placeholders and no image", d)
+	}
+}
+
+func TestLibraryDisplays(t *testing.T) {
+	var album SpotifyAlbumItem
+	album.Album.Name, album.Album.URI = "", "spotify:album:a"
+	if row := displayAlbum(album).libraryItem(); row.name != UNKNOWN_TITLE || row.artist != UNKNOWN_ARTIST {
+		t.Errorf("album without name or artists shown as %q by %q", row.name, row.artist)
+	}
+
+	playlist := SpotifyPlaylistItem{Name: "Mix", URI: "spotify:playlist:p"}
+	if d := displayPlaylist(playlist); d.Artist() != UNKNOWN_ARTIST || d.Kind != "playlist" {
+		t.Errorf("playlist without owner shown by %q as a %q", d.Artist(), d.Kind)
+	}
+
+	show := SpotifyShow{Name: "The Show", Publisher: "Some Network", URI: "spotify:show:s"}
+	if d := displayShow(show); d.FirstArtist() != "Some Network" || d.Subtitle != "Some Network" {
+		t.Errorf("show shown by %q, subtitle %q", d.FirstArtist(), d.Subtitle)
+	}
+
+	if d := displayEpisode(SpotifyEpisode{URI: "spotify:episode:e"}); d.Title != UNKNOWN_TITLE || d.Kind != "episode" {
+		t.Errorf("episode without name shown as %q, a %q", d.Title, d.Kind)
+	}
+}
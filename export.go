@@ -0,0 +1,610 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ===================================================================
+// ===== export.go | Back up and share the library and favorites =====
+// ===================================================================
+
+const EXPORT_VERSION = 1
+
+// EXPORT_FORMATS are the file formats export and import understand, keyed by file extension.
+var EXPORT_FORMATS = []string{"json", "csv", "xspf", "m3u"}
+
+// EXPORT_SECTIONS are the parts of the library that can be exported.
+var EXPORT_SECTIONS = []string{"albums", "playlists", "liked", "favorites"}
+
+// LibraryExport is a full export of the library, as written to JSON.
+type LibraryExport struct {
+	Version    int                `json:"version"`
+	ExportedAt time.Time          `json:"exported_at"`
+	Albums     []ExportCollection `json:"albums,omitempty"`
+	Playlists  []ExportCollection `json:"playlists,omitempty"`
+	LikedSongs []ExportTrack      `json:"liked_songs,omitempty"`
+	Favorites  []LibraryFavorite  `json:"favorites,omitempty"`
+}
+
+// ExportCollection is an exported album or playlist. Only playlists carry their track listing.
+type ExportCollection struct {
+	Name   string        `json:"name"`
+	Owner  string        `json:"owner"`
+	URI    string        `json:"uri"`
+	Tracks []ExportTrack `json:"tracks,omitempty"`
+}
+
+// ExportTrack is an exported track.
+type ExportTrack struct {
+	Name       string `json:"name"`
+	Artists    string `json:"artists"`
+	Album      string `json:"album"`
+	URI        string `json:"uri"`
+	DurationMs int    `json:"duration_ms"`
+}
+
+// exportRow is one line of a flat export (CSV, M3U, XSPF), and what every import format is read into.
+type exportRow struct {
+	Collection string
+	Kind       string
+	Name       string
+	Artists    string
+	Album      string
+	URI        string
+	DurationMs int
+	favorite   *LibraryFavorite // The whole favorite when read from JSON, so its group, tags and slot survive an import
+}
+
+// exportDoneMsg reports the result of an in-app export.
+type exportDoneMsg struct {
+	path string
+	err  error
+}
+
+// =================
+// ===== Fetch =====
+// =================
+
+// newExportTrack converts an API track.
+func newExportTrack(track SpotifyTrack) ExportTrack {
+	artists := make([]string, 0, len(track.Artists))
+	for _, artist := range track.Artists {
+		artists = append(artists, artist.Name)
+	}
+	return ExportTrack{
+		Name:       track.Name,
+		Artists:    strings.Join(artists, ", "),
+		Album:      track.Album.Name,
+		URI:        track.URI,
+		DurationMs: track.DurationMs,
+	}
+}
+
+// buildLibraryExport fetches the chosen sections of the library.
+//
+// Parameters:
+// - ctx: Cancels the fetches.
+// - token: Spotify access token.
+// - favorites: The favorites store.
+// - sections: Which of EXPORT_SECTIONS to include.
+//
+// Returns:
+// - The export.
+// - An error if any section failed to fetch, rather than exporting it empty.
+func buildLibraryExport(ctx context.Context, token string, favorites *FavoritesStore, sections map[string]bool) (LibraryExport, error) {
+	export := LibraryExport{Version: EXPORT_VERSION, ExportedAt: time.Now()}
+
+	if sections["albums"] {
+		albums, err := handleFetchAll[SpotifyAlbumItem](ctx, "/me/albums", token)
+		if err != nil {
+			return export, fmt.Errorf("fetching albums: %w", err)
+		}
+		for _, item := range albums {
+			owner := ""
+			if len(item.Album.Artists) > 0 {
+				owner = item.Album.Artists[0].Name
+			}
+			export.Albums = append(export.Albums, ExportCollection{Name: item.Album.Name, Owner: owner, URI: item.Album.URI})
+		}
+	}
+	if sections["playlists"] {
+		playlists, err := handleFetchAll[SpotifyPlaylistItem](ctx, "/me/playlists", token)
+		if err != nil {
+			return export, fmt.Errorf("fetching playlists: %w", err)
+		}
+		for _, playlist := range playlists {
+			collection := ExportCollection{Name: playlist.Name, Owner: playlist.Owner.DisplayName, URI: playlist.URI}
+			tracks, err := handleFetchAll[SpotifySavedTrack](ctx, fmt.Sprintf("/playlists/%s/tracks", playlist.ID), token)
+			if err != nil {
+				return export, fmt.Errorf("fetching playlist %s: %w", playlist.Name, err)
+			}
+			for _, item := range tracks {
+				if item.Track != nil {
+					collection.Tracks = append(collection.Tracks, newExportTrack(*item.Track))
+				}
+			}
+			export.Playlists = append(export.Playlists, collection)
+		}
+	}
+	if sections["liked"] {
+		liked, err := handleFetchAll[SpotifySavedTrack](ctx, "/me/tracks", token)
+		if err != nil {
+			return export, fmt.Errorf("fetching Liked Songs: %w", err)
+		}
+		for _, item := range liked {
+			if item.Track != nil {
+				export.LikedSongs = append(export.LikedSongs, newExportTrack(*item.Track))
+			}
+		}
+	}
+	if sections["favorites"] {
+		export.Favorites = favorites.All()
+	}
+	return export, nil
+}
+
+// rows flattens an export for the line based formats.
+func (e LibraryExport) rows() []exportRow {
+	var rows []exportRow
+	for _, album := range e.Albums {
+		rows = append(rows, exportRow{Collection: "Albums", Kind: "album", Name: album.Name, Artists: album.Owner, URI: album.URI})
+	}
+	for _, playlist := range e.Playlists {
+		rows = append(rows, exportRow{Collection: "Playlists", Kind: "playlist", Name: playlist.Name, Artists: playlist.Owner, URI: playlist.URI})
+		for _, track := range playlist.Tracks {
+			rows = append(rows, trackRow(playlist.Name, track))
+		}
+	}
+	for _, track := range e.LikedSongs {
+		rows = append(rows, trackRow("Liked Songs", track))
+	}
+	for _, favorite := range e.Favorites {
+		rows = append(rows, exportRow{Collection: "Favorites", Kind: uriKind(favorite.URI), Name: favorite.Title, Artists: favorite.Author, URI: favorite.URI, favorite: &favorite})
+	}
+	return rows
+}
+
+// trackRow makes a flat row for a track in a collection.
+func trackRow(collection string, track ExportTrack) exportRow {
+	return exportRow{
+		Collection: collection,
+		Kind:       uriKind(track.URI),
+		Name:       track.Name,
+		Artists:    track.Artists,
+		Album:      track.Album,
+		URI:        track.URI,
+		DurationMs: track.DurationMs,
+	}
+}
+
+// ===================
+// ===== Writing =====
+// ===================
+
+// writeExport writes an export in a format.
+func writeExport(w io.Writer, export LibraryExport, format string) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(export)
+	case "csv":
+		return writeExportCSV(w, export.rows())
+	case "m3u":
+		return writeExportM3U(w, export.rows())
+	case "xspf":
+		return writeExportXSPF(w, export.rows())
+	}
+	return fmt.Errorf("unknown format %q, expected one of %s", format, strings.Join(EXPORT_FORMATS, ", "))
+}
+
+// csvHeader is the header line of CSV exports, which imports look for by name.
+var csvHeader = []string{"collection", "kind", "name", "artists", "album", "uri", "duration_ms"}
+
+func writeExportCSV(w io.Writer, rows []exportRow) error {
+	writer := csv.NewWriter(w)
+	writer.Write(csvHeader)
+	for _, row := range rows {
+		writer.Write([]string{row.Collection, row.Kind, row.Name, row.Artists, row.Album, row.URI, strconv.Itoa(row.DurationMs)})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func writeExportM3U(w io.Writer, rows []exportRow) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintln(out, "#EXTM3U")
+	collection := ""
+	for _, row := range rows {
+		if row.Collection != collection {
+			collection = row.Collection
+			fmt.Fprintf(out, "#EXTGRP:%s\n", collection)
+		}
+		seconds := -1
+		if row.DurationMs > 0 {
+			seconds = row.DurationMs / 1000
+		}
+		fmt.Fprintf(out, "#EXTINF:%d,%s - %s\n", seconds, row.Artists, row.Name)
+		fmt.Fprintln(out, row.URI)
+	}
+	return out.Flush()
+}
+
+// xspfPlaylist is the XML layout of an XSPF playlist.
+type xspfPlaylist struct {
+	XMLName xml.Name    `xml:"playlist"`
+	Version string      `xml:"version,attr"`
+	XMLNS   string      `xml:"xmlns,attr"`
+	Title   string      `xml:"title"`
+	Date    string      `xml:"date,omitempty"`
+	Tracks  []xspfTrack `xml:"trackList>track"`
+}
+
+type xspfTrack struct {
+	Location   string `xml:"location"`
+	Title      string `xml:"title,omitempty"`
+	Creator    string `xml:"creator,omitempty"`
+	Album      string `xml:"album,omitempty"`
+	Duration   int    `xml:"duration,omitempty"`
+	Annotation string `xml:"annotation,omitempty"`
+}
+
+func writeExportXSPF(w io.Writer, rows []exportRow) error {
+	playlist := xspfPlaylist{Version: "1", XMLNS: "http://xspf.org/ns/0/", Title: "JukeTUI export", Date: time.Now().Format(time.RFC3339)}
+	for _, row := range rows {
+		playlist.Tracks = append(playlist.Tracks, xspfTrack{
+			Location:   row.URI,
+			Title:      row.Name,
+			Creator:    row.Artists,
+			Album:      row.Album,
+			Duration:   row.DurationMs,
+			Annotation: row.Collection,
+		})
+	}
+	io.WriteString(w, xml.Header)
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(playlist); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// ===================
+// ===== Reading =====
+// ===================
+
+// readImport reads the items in an import file, choosing the format by extension.
+func readImport(path string) ([]exportRow, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return readImportJSON(file)
+	case ".csv":
+		return readImportCSV(file)
+	case ".m3u", ".m3u8":
+		return readImportM3U(file)
+	case ".xspf":
+		return readImportXSPF(file)
+	}
+	return nil, fmt.Errorf("unknown import format %q, expected one of %s", filepath.Ext(path), strings.Join(EXPORT_FORMATS, ", "))
+}
+
+// readImportJSON reads a JukeTUI export, or a favorites file of any version.
+func readImportJSON(r io.Reader) ([]exportRow, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var export LibraryExport
+	if err := json.Unmarshal(data, &export); err == nil && export.Version > 0 && !export.ExportedAt.IsZero() {
+		return export.rows(), nil
+	}
+	file, _, err := decodeFavorites(data)
+	if err != nil {
+		return nil, fmt.Errorf("not a JukeTUI export or favorites file: %w", err)
+	}
+	return LibraryExport{Favorites: file.Favorites}.rows(), nil
+}
+
+func readImportCSV(r io.Reader) ([]exportRow, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	columns := map[string]int{}
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["uri"]; !ok {
+		return nil, errors.New("CSV has no uri column")
+	}
+	get := func(record []string, column string) string {
+		if i, ok := columns[column]; ok && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+
+	var rows []exportRow
+	for _, record := range records[1:] {
+		duration, _ := strconv.Atoi(get(record, "duration_ms"))
+		rows = append(rows, exportRow{
+			Collection: get(record, "collection"),
+			Kind:       uriKind(get(record, "uri")),
+			Name:       get(record, "name"),
+			Artists:    get(record, "artists"),
+			Album:      get(record, "album"),
+			URI:        get(record, "uri"),
+			DurationMs: duration,
+		})
+	}
+	return rows, nil
+}
+
+func readImportM3U(r io.Reader) ([]exportRow, error) {
+	var rows []exportRow
+	var pending exportRow
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "#EXTGRP:"):
+			pending.Collection = strings.TrimPrefix(line, "#EXTGRP:")
+		case strings.HasPrefix(line, "#EXTINF:"):
+			info := strings.TrimPrefix(line, "#EXTINF:")
+			if seconds, title, ok := strings.Cut(info, ","); ok {
+				if s, err := strconv.Atoi(seconds); err == nil && s > 0 {
+					pending.DurationMs = s * 1000
+				}
+				pending.Artists, pending.Name, _ = strings.Cut(title, " - ")
+			}
+		case line == "" || strings.HasPrefix(line, "#"):
+		default:
+			pending.URI = line
+			pending.Kind = uriKind(line)
+			rows = append(rows, pending)
+			pending = exportRow{Collection: pending.Collection}
+		}
+	}
+	return rows, scanner.Err()
+}
+
+func readImportXSPF(r io.Reader) ([]exportRow, error) {
+	var playlist xspfPlaylist
+	if err := xml.NewDecoder(r).Decode(&playlist); err != nil {
+		return nil, err
+	}
+	rows := make([]exportRow, 0, len(playlist.Tracks))
+	for _, track := range playlist.Tracks {
+		rows = append(rows, exportRow{
+			Collection: track.Annotation,
+			Kind:       uriKind(track.Location),
+			Name:       track.Title,
+			Artists:    track.Creator,
+			Album:      track.Album,
+			URI:        strings.TrimSpace(track.Location),
+			DurationMs: track.Duration,
+		})
+	}
+	return rows, nil
+}
+
+// =====================
+// ===== Importing =====
+// =====================
+
+// importFavorites adds every Spotify item in the rows to the favorites, saving once.
+// A JukeTUI export holds the whole library, so only its Favorites section is imported.
+// Favorites read from JSON come back whole; the flat formats only carry a title, author and URI.
+func importFavorites(store *FavoritesStore, rows []exportRow) (int, error) {
+	var favorites []LibraryFavorite
+	for _, row := range favoriteRows(rows) {
+		switch {
+		case row.favorite != nil:
+			favorites = append(favorites, *row.favorite)
+		case row.Kind != "":
+			favorites = append(favorites, LibraryFavorite{Title: row.Name, Author: row.Artists, URI: row.URI})
+		}
+	}
+	return store.AddAll(favorites)
+}
+
+// favoriteRows picks the rows of an import to make favorites: the Favorites section of a JukeTUI export
+// (in any format, told apart by its section names), or every row of any other file.
+func favoriteRows(rows []exportRow) []exportRow {
+	var favorites []exportRow
+	fromExport := false
+	for _, row := range rows {
+		switch row.Collection {
+		case "Favorites":
+			favorites = append(favorites, row)
+			fromExport = true
+		case "Albums", "Playlists", "Liked Songs":
+			fromExport = true
+		}
+	}
+	if !fromExport {
+		return rows
+	}
+	return favorites
+}
+
+// importPlaylist creates a private playlist holding every track and episode in the rows.
+func importPlaylist(ctx context.Context, token, name string, rows []exportRow) (SpotifyPlaylistItem, int, error) {
+	var uris []string
+	for _, row := range rows {
+		if row.Kind == "track" || row.Kind == "episode" {
+			uris = append(uris, row.URI)
+		}
+	}
+	if len(uris) == 0 {
+		return SpotifyPlaylistItem{}, 0, errors.New("nothing to add, the file has no tracks or episodes")
+	}
+
+	user, err := handleGenericFetch[SpotifyUser](ctx, "/me", token, nil, nil)
+	if err != nil {
+		return SpotifyPlaylistItem{}, 0, fmt.Errorf("fetching the current user: %w", err)
+	}
+	playlist, err := handleGenericCreate[SpotifyPlaylistItem](ctx, fmt.Sprintf("/users/%s/playlists", user.ID), token, nil, map[string]any{
+		"name":        name,
+		"public":      false,
+		"description": "Imported by JukeTUI",
+	})
+	if err != nil {
+		return playlist, 0, err
+	}
+
+	const batchSize = 100 // Most the API takes in one request
+	for start := 0; start < len(uris); start += batchSize {
+		batch := uris[start:min(start+batchSize, len(uris))]
+		if _, err := handleGenericPost(ctx, fmt.Sprintf("/playlists/%s/tracks", playlist.ID), token, nil, map[string]any{"uris": batch}); err != nil {
+			return playlist, start, err
+		}
+	}
+	return playlist, len(uris), nil
+}
+
+// ===========================
+// ===== Commands and UI =====
+// ===========================
+
+// parseSections turns a comma separated list into a set of export sections.
+func parseSections(list string) (map[string]bool, error) {
+	sections := map[string]bool{}
+	for _, section := range strings.Split(list, ",") {
+		section = strings.TrimSpace(section)
+		valid := false
+		for _, known := range EXPORT_SECTIONS {
+			valid = valid || known == section
+		}
+		if !valid {
+			return nil, fmt.Errorf("unknown section %q, expected some of %s", section, strings.Join(EXPORT_SECTIONS, ","))
+		}
+		sections[section] = true
+	}
+	return sections, nil
+}
+
+// runExport implements `juketui export`.
+func runExport(ctx context.Context, token string, favorites *FavoritesStore, args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	format := flags.String("format", "json", "Output format: "+strings.Join(EXPORT_FORMATS, ", "))
+	out := flags.String("out", "", "File to write to (default stdout)")
+	what := flags.String("sections", strings.Join(EXPORT_SECTIONS, ","), "Comma separated parts of the library to export")
+	flags.Parse(args)
+
+	sections, err := parseSections(*what)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	fmt.Fprintln(os.Stderr, "Fetching library...")
+	export, err := buildLibraryExport(ctx, token, favorites, sections)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Export failed: ", err)
+		os.Exit(1)
+	}
+
+	var buf strings.Builder
+	if err := writeExport(&buf, export, *format); err != nil {
+		fmt.Fprintln(os.Stderr, "Export failed: ", err)
+		os.Exit(1)
+	}
+	if *out == "" {
+		fmt.Print(buf.String())
+		return
+	}
+	if err := writeFileAtomic(*out, []byte(buf.String())); err != nil {
+		fmt.Fprintln(os.Stderr, "Export failed: ", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d items to %s\n", len(export.rows()), *out)
+}
+
+// runImport implements `juketui import`.
+func runImport(ctx context.Context, token string, favorites *FavoritesStore, args []string) {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	as := flags.String("as", "favorites", "What to import into: favorites or playlist")
+	name := flags.String("name", "", "Name of the playlist to create (default: the file name)")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: juketui import [--as favorites|playlist] [--name NAME] FILE")
+		os.Exit(1)
+	}
+	path := flags.Arg(0)
+	rows, err := readImport(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Import failed: ", err)
+		os.Exit(1)
+	}
+
+	switch *as {
+	case "favorites":
+		added, err := importFavorites(favorites, rows)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Import failed: ", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Added %d favorites\n", added)
+	case "playlist":
+		if *name == "" {
+			*name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		}
+		playlist, added, err := importPlaylist(ctx, token, *name, rows)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Import failed: ", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Created playlist %q (%s) with %d items\n", playlist.Name, playlist.URI, added)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown import target %q, expected favorites or playlist\n", *as)
+		os.Exit(1)
+	}
+}
+
+// exportCmd returns a command that exports the whole library as JSON into the data directory.
+func exportCmd(ctx context.Context, token string, favorites *FavoritesStore) tea.Cmd {
+	return func() tea.Msg {
+		sections := map[string]bool{}
+		for _, section := range EXPORT_SECTIONS {
+			sections[section] = true
+		}
+		export, err := buildLibraryExport(ctx, token, favorites, sections)
+		if err != nil {
+			return exportDoneMsg{err: err}
+		}
+		data, err := json.MarshalIndent(export, "", "  ")
+		if err != nil {
+			return exportDoneMsg{err: err}
+		}
+		path := dataPath(fmt.Sprintf("export-%s.json", export.ExportedAt.Format("2006-01-02-150405")))
+		return exportDoneMsg{path: path, err: writeFileAtomic(path, data)}
+	}
+}
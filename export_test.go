@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestImportFavoritesFromExport(t *testing.T) {
+	export := `{"version":1,"exported_at":"2024-05-01T10:00:00Z",
+		"albums":[{"name":"Album","owner":"Band","uri":"spotify:album:a"}],
+		"playlists":[{"name":"Mix","owner":"me","uri":"spotify:playlist:p","tracks":[{"name":"Song","artists":"Band","uri":"spotify:track:t"}]}],
+		"liked_songs":[{"name":"Liked","artists":"Band","uri":"spotify:track:l"}],
+		"favorites":[{"title":"Fav","author":"Band","URI":"spotify:album:f"},{"title":"Fav 2","author":"Band","URI":"spotify:show:s"}]}`
+	rows, err := readImportJSON(strings.NewReader(export))
+	if err != nil {
+		t.Fatalf("reading export: %v", err)
+	}
+	store, err := openFavorites(filepath.Join(t.TempDir(), FAVORITES_FILE))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Add(LibraryFavorite{Title: "Fav", URI: "spotify:album:f"}); err != nil {
+		t.Fatal(err)
+	}
+
+	added, err := importFavorites(store, rows)
+	if err != nil {
+		t.Fatalf("importing: %v", err)
+	}
+	if added != 1 || len(store.All()) != 2 || !store.Contains("spotify:show:s") || store.Contains("spotify:album:a") {
+		t.Errorf("added %d, favorites %+v, want only the export's favorites section", added, store.All())
+	}
+
+	reopened, err := openFavorites(store.path)
+	if err != nil || len(reopened.All()) != 2 {
+		t.Errorf("saved favorites %+v (%v), want 2", reopened.All(), err)
+	}
+}
+
+func TestFavoriteRowsFromOtherFiles(t *testing.T) {
+	rows := []exportRow{{Kind: "track", URI: "spotify:track:a"}, {Collection: "Road Trip", Kind: "track", URI: "spotify:track:b"}}
+	if got := favoriteRows(rows); len(got) != 2 {
+		t.Errorf("got %d rows, want every row of a file that isn't an export", len(got))
+	}
+}
+
+func TestImportFavoritesRoundTrip(t *testing.T) {
+	saved := []LibraryFavorite{
+		{Title: "Fav", Author: "Band", URI: "spotify:album:f", Group: "Morning", Tags: []string{"chill", "focus"}, Slot: 3},
+		{Title: "Mix", Author: "me", URI: "spotify:playlist:p", Tags: []string{"run"}},
+	}
+	favoritesFile, err := json.Marshal(favoritesFile{Version: FAVORITES_VERSION, Favorites: saved})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var export bytes.Buffer
+	if err := writeExport(&export, LibraryExport{Version: EXPORT_VERSION, ExportedAt: time.Now(), Favorites: saved}, "json"); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, data := range map[string][]byte{"export": export.Bytes(), "favorites file": favoritesFile} {
+		t.Run(name, func(t *testing.T) {
+			rows, err := readImportJSON(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("reading: %v", err)
+			}
+			store, err := openFavorites(filepath.Join(t.TempDir(), FAVORITES_FILE))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := importFavorites(store, rows); err != nil {
+				t.Fatalf("importing: %v", err)
+			}
+			if got := store.All(); !reflect.DeepEqual(got, saved) {
+				t.Errorf("imported %+v, want %+v", got, saved)
+			}
+		})
+	}
+}
+
+func TestImportFavoritesKeepsBoundSlots(t *testing.T) {
+	store, err := openFavorites(filepath.Join(t.TempDir(), FAVORITES_FILE))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Add(LibraryFavorite{Title: "Mine", URI: "spotify:album:m", Slot: 3}); err != nil {
+		t.Fatal(err)
+	}
+	imported := LibraryFavorite{Title: "Fav", URI: "spotify:album:f", Slot: 3}
+	if _, err := importFavorites(store, LibraryExport{Favorites: []LibraryFavorite{imported}}.rows()); err != nil {
+		t.Fatal(err)
+	}
+	if favorite, _ := store.BySlot(3); favorite.URI != "spotify:album:m" || len(store.All()) != 2 {
+		t.Errorf("slot 3 plays %q, want it kept by the existing favorite", favorite.URI)
+	}
+}
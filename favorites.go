@@ -0,0 +1,572 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ====================================================
+// ===== favorites.go | Persisted favorites store =====
+// ====================================================
+
+// FAVORITES_FILE is the unified favorites file, inside the data directory.
+const FAVORITES_FILE = "favorites.json"
+
+// FAVORITES_VERSION is the current schema version of the favorites file.
+//
+// Versions:
+// - 1: A bare JSON array of favorites (files written before versioning).
+// - 2: An object with a version and the favorites, keyed by URI. Favorites may have a group, tags and a slot.
+const FAVORITES_VERSION = 2
+
+// LEGACY_FAVORITES are the per-library favorites files from before favorites were unified, relative to the working directory.
+var LEGACY_FAVORITES = []string{"favorites/albums.json", "favorites/playlists.json"}
+
+// favoritesPageMsg tells the update to show a page of the Favorites view.
+type favoritesPageMsg struct{}
+
+// MAX_SLOT is the highest number key a favorite can be bound to.
+const MAX_SLOT = 9
+
+// favoritesFile is the on disk layout of the favorites file.
+type favoritesFile struct {
+	Version   int               `json:"version"`
+	Favorites []LibraryFavorite `json:"favorites"`
+}
+
+// favoritesMigrations upgrade raw file contents from the version at their index+1 to the next version.
+var favoritesMigrations = []func(data []byte) ([]byte, error){
+	migrateFavoritesV1,
+}
+
+// FavoritesStore is the favorites repository. Entries are unique by URI and every change is written atomically.
+type FavoritesStore struct {
+	path      string
+	mu        sync.Mutex
+	favorites []LibraryFavorite
+}
+
+// openFavorites loads the favorites file, creating it if it doesn't exist and migrating it if it's old.
+//
+// Parameters:
+// - path: Path to the favorites file.
+//
+// Returns:
+// - The loaded store.
+// - An error if the file exists but can't be read or understood.
+func openFavorites(path string) (*FavoritesStore, error) {
+	store := &FavoritesStore{path: path}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		infoLogger.Printf("No favorites at %s, creating a new file", path)
+		return store, store.save()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read favorites: %w", err)
+	}
+
+	file, migrated, err := decodeFavorites(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse favorites %s: %w", path, err)
+	}
+	for _, favorite := range file.Favorites {
+		store.put(favorite)
+	}
+	store.normalize()
+	if migrated {
+		infoLogger.Printf("Migrated favorites %s to version %d", path, FAVORITES_VERSION)
+		if err := store.save(); err != nil {
+			return nil, err
+		}
+	}
+	return store, nil
+}
+
+// migrateLegacyFavorites imports the old per-library favorites files into a new unified favorites file.
+// Does nothing once the unified file exists. The legacy files are left in place.
+//
+// Parameters:
+// - path: Path to the unified favorites file.
+// - legacy: Paths of the legacy files to import.
+//
+// Returns:
+// - An error if a legacy file exists but can't be imported.
+func migrateLegacyFavorites(path string, legacy []string) error {
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+
+	store := &FavoritesStore{path: path}
+	usedSlots := map[int]bool{}
+	found := false
+	for _, legacyPath := range legacy {
+		data, err := os.ReadFile(legacyPath)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read legacy favorites %s: %w", legacyPath, err)
+		}
+		file, _, err := decodeFavorites(data)
+		if err != nil {
+			return fmt.Errorf("failed to parse legacy favorites %s: %w", legacyPath, err)
+		}
+		for _, favorite := range file.Favorites {
+			if usedSlots[favorite.Slot] { // Both files may have used the same number key
+				favorite.Slot = 0
+			}
+			if favorite.Slot != 0 {
+				usedSlots[favorite.Slot] = true
+			}
+			store.put(favorite)
+		}
+		found = true
+	}
+	if !found {
+		return nil
+	}
+	store.normalize()
+	infoLogger.Printf("Migrated %d legacy favorites to %s", len(store.favorites), path)
+	return store.save()
+}
+
+// loadFavorites migrates and opens the unified favorites file, exiting if it can't be used.
+// Only for use before the TUI starts.
+func loadFavorites() *FavoritesStore {
+	if err := migrateLegacyFavorites(dataPath(FAVORITES_FILE), LEGACY_FAVORITES); err != nil {
+		log.Fatalf("Failed to migrate favorites: %v", err)
+	}
+	store, err := openFavorites(dataPath(FAVORITES_FILE))
+	if err != nil {
+		log.Fatalf("Failed to load favorites: %v", err)
+	}
+	return store
+}
+
+// decodeFavorites parses a favorites file of any version, running migrations up to the current one.
+func decodeFavorites(data []byte) (favoritesFile, bool, error) {
+	var file favoritesFile
+	version := 1
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return favoritesFile{Version: FAVORITES_VERSION}, true, nil
+	}
+	migrated := false
+	if trimmed[0] == '{' {
+		var header struct {
+			Version *int `json:"version"`
+		}
+		if err := json.Unmarshal(trimmed, &header); err != nil {
+			return file, false, err
+		}
+		version = FAVORITES_VERSION
+		if header.Version == nil { // Only version 2 and later are objects, so take it as the current schema and write the version back
+			migrated = true
+		} else {
+			version = *header.Version
+		}
+	}
+	if version < 1 {
+		return file, false, fmt.Errorf("favorites version %d is not a valid version", version)
+	}
+	if version > FAVORITES_VERSION {
+		return file, false, fmt.Errorf("favorites version %d is newer than this JukeTUI understands (%d)", version, FAVORITES_VERSION)
+	}
+
+	for ; version < FAVORITES_VERSION; version++ {
+		var err error
+		if trimmed, err = favoritesMigrations[version-1](trimmed); err != nil {
+			return file, false, fmt.Errorf("migrating from version %d: %w", version, err)
+		}
+		migrated = true
+	}
+	err := json.Unmarshal(trimmed, &file)
+	return file, migrated, err
+}
+
+// migrateFavoritesV1 wraps a bare array of favorites in a versioned object.
+func migrateFavoritesV1(data []byte) ([]byte, error) {
+	var favorites []LibraryFavorite
+	if err := json.Unmarshal(data, &favorites); err != nil {
+		return nil, err
+	}
+	if favorites == nil {
+		favorites = []LibraryFavorite{}
+	}
+	return json.Marshal(favoritesFile{Version: 2, Favorites: favorites})
+}
+
+// All returns a copy of every favorite, in order.
+func (s *FavoritesStore) All() []LibraryFavorite {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]LibraryFavorite{}, s.favorites...)
+}
+
+// Contains reports whether a URI is a favorite.
+func (s *FavoritesStore) Contains(uri string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.index(uri) >= 0
+}
+
+// Add saves a favorite. Adding a URI that is already a favorite updates it in place.
+func (s *FavoritesStore) Add(favorite LibraryFavorite) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := append([]LibraryFavorite{}, s.favorites...)
+	s.put(favorite)
+	s.normalize()
+	if err := s.save(); err != nil {
+		s.favorites = previous
+		return err
+	}
+	return nil
+}
+
+// AddAll saves many new favorites with a single write, skipping URIs that are already favorites.
+// A number key already bound to another favorite stays with it, so the newcomer is added without one.
+// Returns how many were added.
+func (s *FavoritesStore) AddAll(favorites []LibraryFavorite) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := append([]LibraryFavorite{}, s.favorites...)
+	added := 0
+	for _, favorite := range favorites {
+		if s.index(favorite.URI) >= 0 {
+			continue
+		}
+		for _, existing := range s.favorites {
+			if favorite.Slot != 0 && existing.Slot == favorite.Slot {
+				favorite.Slot = 0
+			}
+		}
+		s.put(favorite)
+		added++
+	}
+	if added == 0 {
+		return 0, nil
+	}
+	s.normalize()
+	if err := s.save(); err != nil {
+		s.favorites = previous
+		return 0, err
+	}
+	return added, nil
+}
+
+// Remove deletes the favorite with a URI. Removing something that isn't a favorite is not an error.
+func (s *FavoritesStore) Remove(uri string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.index(uri)
+	if i < 0 {
+		return nil
+	}
+	previous := append([]LibraryFavorite{}, s.favorites...)
+	s.favorites = append(s.favorites[:i], s.favorites[i+1:]...)
+	if err := s.save(); err != nil {
+		s.favorites = previous
+		return err
+	}
+	return nil
+}
+
+// Update changes the favorite with a URI in place, e.g. to set its group or tags.
+func (s *FavoritesStore) Update(uri string, change func(*LibraryFavorite)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.index(uri)
+	if i < 0 {
+		return fmt.Errorf("%s is not a favorite", uri)
+	}
+	previous := append([]LibraryFavorite{}, s.favorites...)
+	change(&s.favorites[i])
+	s.normalize()
+	if err := s.save(); err != nil {
+		s.favorites = previous
+		return err
+	}
+	return nil
+}
+
+// Move swaps a favorite with its nearest visible neighbor above (negative delta) or below, within its group.
+// Favorites the view hides, like other kinds or ones without the filtered tag, are stepped over and keep their place.
+// Without a visible neighbor in the group it does nothing, and reports false.
+func (s *FavoritesStore) Move(uri string, delta int, visible func(LibraryFavorite) bool) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.index(uri)
+	if i < 0 || delta == 0 {
+		return false, nil
+	}
+	j := i + delta
+	for j >= 0 && j < len(s.favorites) && !visible(s.favorites[j]) {
+		j += delta
+	}
+	if j < 0 || j >= len(s.favorites) || s.favorites[i].Group != s.favorites[j].Group {
+		return false, nil
+	}
+	previous := append([]LibraryFavorite{}, s.favorites...)
+	s.favorites[i], s.favorites[j] = s.favorites[j], s.favorites[i]
+	if err := s.save(); err != nil {
+		s.favorites = previous
+		return false, err
+	}
+	return true, nil
+}
+
+// AssignSlot binds a favorite to a number key, taking the slot away from any other favorite. Slot 0 unbinds it.
+func (s *FavoritesStore) AssignSlot(uri string, slot int) error {
+	if slot < 0 || slot > MAX_SLOT {
+		return fmt.Errorf("slot must be between 1 and %d", MAX_SLOT)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.index(uri)
+	if i < 0 {
+		return fmt.Errorf("%s is not a favorite", uri)
+	}
+	previous := append([]LibraryFavorite{}, s.favorites...)
+	for j := range s.favorites {
+		if slot != 0 && s.favorites[j].Slot == slot {
+			s.favorites[j].Slot = 0
+		}
+	}
+	s.favorites[i].Slot = slot
+	if err := s.save(); err != nil {
+		s.favorites = previous
+		return err
+	}
+	return nil
+}
+
+// BySlot finds the favorite bound to a number key.
+func (s *FavoritesStore) BySlot(slot int) (LibraryFavorite, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, favorite := range s.favorites {
+		if favorite.Slot == slot {
+			return favorite, true
+		}
+	}
+	return LibraryFavorite{}, false
+}
+
+// Tags returns every tag in use, sorted.
+func (s *FavoritesStore) Tags() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen := map[string]bool{}
+	var tags []string
+	for _, favorite := range s.favorites {
+		for _, tag := range favorite.Tags {
+			if !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// normalize keeps each group's favorites together, with groups in the order they first appear
+// and ungrouped favorites last. Order within a group is kept. Callers hold the lock.
+func (s *FavoritesStore) normalize() {
+	rank := map[string]int{}
+	for _, favorite := range s.favorites {
+		if _, ok := rank[favorite.Group]; !ok && favorite.Group != "" {
+			rank[favorite.Group] = len(rank)
+		}
+	}
+	rank[""] = len(rank)
+	sort.SliceStable(s.favorites, func(i, j int) bool {
+		return rank[s.favorites[i].Group] < rank[s.favorites[j].Group]
+	})
+}
+
+// index finds the position of a URI, or -1. Callers hold the lock.
+func (s *FavoritesStore) index(uri string) int {
+	for i, favorite := range s.favorites {
+		if favorite.URI == uri {
+			return i
+		}
+	}
+	return -1
+}
+
+// put inserts or replaces a favorite by URI. Callers hold the lock.
+func (s *FavoritesStore) put(favorite LibraryFavorite) {
+	if i := s.index(favorite.URI); i >= 0 {
+		s.favorites[i] = favorite
+		return
+	}
+	s.favorites = append(s.favorites, favorite)
+}
+
+// save writes the favorites through a temporary file, fsync and rename, so a crash never leaves a half written file.
+func (s *FavoritesStore) save() error {
+	favorites := s.favorites
+	if favorites == nil {
+		favorites = []LibraryFavorite{}
+	}
+	data, err := json.MarshalIndent(favoritesFile{Version: FAVORITES_VERSION, Favorites: favorites}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode favorites: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create favorites directory: %w", err)
+	}
+	if err := writeFileAtomic(s.path, data); err != nil {
+		return fmt.Errorf("failed to save favorites: %w", err)
+	}
+	return syncDir(filepath.Dir(s.path))
+}
+
+// ===============================
+// ===== Library Integration =====
+// ===============================
+
+// libraryFavorites gets the favorites pinned to the top of the current library: those of the same kind.
+func (m Model) libraryFavorites() []LibraryFavorite {
+	var favorites []LibraryFavorite
+	for _, favorite := range m.favorites {
+		if uriKind(favorite.URI) == m.listDetail {
+			favorites = append(favorites, favorite)
+		}
+	}
+	return favorites
+}
+
+// favoriteVisible reports whether a favorite is shown in the current library view: the right kind, and carrying the filtered tag.
+func (m Model) favoriteVisible(favorite LibraryFavorite) bool {
+	if m.listDetail != "favorites" && uriKind(favorite.URI) != m.listDetail {
+		return false
+	}
+	return m.tagFilter == "" || favorite.HasTag(m.tagFilter)
+}
+
+// favoriteItem turns a favorite into a library row. The Favorites view mixes kinds, so there each row is labelled with its kind.
+func favoriteItem(favorite LibraryFavorite, showKind bool) LibraryItem {
+	artist := favorite.Author
+	if showKind {
+		artist = fmt.Sprintf("%s (%s)", favorite.Author, uriKind(favorite.URI))
+	}
+	return LibraryItem{name: favorite.Title, artist: artist, uri: favorite.URI, favorite: true, group: favorite.Group, slot: favorite.Slot}
+}
+
+// notifyFavorite tells the user whether an item just became a favorite or stopped being one.
+func (m Model) notifyFavorite(uri, name string) Model {
+	if m.favoriteStore.Contains(uri) {
+		return m.notify(NOTICE_INFO, "Added "+name+" to favorites")
+	}
+	return m.notify(NOTICE_INFO, "Removed "+name+" from favorites")
+}
+
+// reloadLibrary refreshes the library pane (or artist or show page) after the favorites or the page change.
+func (m Model) reloadLibrary() (Model, tea.Cmd) {
+	m.favorites = m.favoriteStore.All()
+	if m.artist != nil { // Only the favorite markers can have changed
+		m.libraryList = m.artist.rows(m.favoriteStore)
+		return m, nil
+	}
+	if m.show != nil {
+		m.libraryList = m.show.rows(m.favoriteStore)
+		return m, nil
+	}
+	m.pager = m.libraryPager()
+	return m.fetchLibrary(func(context.Context) tea.Cmd {
+		return handleFetchLibrary(m.libraryCache, m.history, m.favorites, m.listDetail, m.settings.SortMode(m.listDetail), m.libraryFilter(), m.pager) // Local, so nothing to cancel
+	})
+}
+
+// followMovedFavorite keeps the cursor on a favorite that just moved a row. Where favorites are paged,
+// moving past the top or bottom row swaps with a favorite on the neighboring page, so the page turns with it.
+func (m Model) followMovedFavorite(delta int) Model {
+	row := m.cursor + delta
+	switch {
+	case row < 0 && m.pager.Page() > 0:
+		m.pager, row = m.pager.Prev(), m.pager.PageSize()-1
+	case row >= len(m.libraryList) && m.pager.Page() < m.pager.Pages()-1:
+		m.pager, row = m.pager.Next(), 0
+	}
+	m.cursor = max(0, min(row, len(m.libraryList)-1))
+	return m
+}
+
+// libraryFilter gets the tag filter and search currently narrowing the library.
+func (m Model) libraryFilter() libraryFilter {
+	return libraryFilter{tag: m.tagFilter, search: m.search}
+}
+
+// libraryPager gets the paginator sized for the current library. Favorite albums and playlists
+// are pinned above the page in their own library; the Favorites view, search results and
+// tag filtered libraries page through everything.
+func (m Model) libraryPager() Paginator {
+	pinned := len(m.libraryFavorites())
+	if m.listDetail == "favorites" || m.search != "" || m.tagFilter != "" {
+		pinned = 0
+	}
+	return m.pager.Resize(m.height-LIBRARY_SPACING, pinned)
+}
+
+// showFavoritesPage fills the library with a page of the Favorites view, which can hold any kind of item.
+func (m Model) showFavoritesPage() Model {
+	var favorites []LibraryFavorite
+	for _, favorite := range m.favorites {
+		if m.tagFilter == "" || favorite.HasTag(m.tagFilter) {
+			favorites = append(favorites, favorite)
+		}
+	}
+	page, total := pageItems(m.pager, favorites, nil)
+	m.pager = m.pager.WithTotal(total)
+
+	m.libraryList = []LibraryItem{}
+	for _, favorite := range page {
+		m.libraryList = append(m.libraryList, favoriteItem(favorite, true))
+	}
+	m.loading = false
+	m.cursor = max(0, min(m.cursor, len(m.libraryList)-1))
+	return m
+}
+
+// showTaggedPage fills the library with a page of its favorites carrying the tag filter.
+// Only favorites can have tags, so with a filter they are the whole library, paged like any other.
+func (m Model) showTaggedPage() Model {
+	var tagged []LibraryFavorite
+	for _, favorite := range m.libraryFavorites() {
+		if favorite.HasTag(m.tagFilter) {
+			tagged = append(tagged, favorite)
+		}
+	}
+	page, total := pageItems(m.pager, tagged, nil)
+	m.pager = m.pager.WithTotal(total)
+
+	m.libraryList = []LibraryItem{}
+	for _, favorite := range page {
+		m.libraryList = append(m.libraryList, favoriteItem(favorite, false))
+	}
+	m.loading = false
+	m.cursor = max(0, min(m.cursor, len(m.libraryList)-1))
+	return m
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDecodeFavoritesVersions(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		wantErr   string
+		migrated  bool
+		favorites int
+	}{
+		{name: "empty file", data: "", migrated: true},
+		{name: "version 1 array", data: `[{"title":"A","URI":"spotify:album:a"}]`, migrated: true, favorites: 1},
+		{name: "current version", data: `{"version":2,"favorites":[{"title":"A","URI":"spotify:album:a"}]}`, favorites: 1},
+		{name: "missing version", data: `{"favorites":[{"title":"A","URI":"spotify:album:a"}]}`, migrated: true, favorites: 1},
+		{name: "zero version", data: `{"version":0,"favorites":[]}`, wantErr: "not a valid version"},
+		{name: "negative version", data: `{"version":-3,"favorites":[]}`, wantErr: "not a valid version"},
+		{name: "future version", data: `{"version":99,"favorites":[]}`, wantErr: "newer than this JukeTUI understands"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, migrated, err := decodeFavorites([]byte(tt.data))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if migrated != tt.migrated {
+				t.Errorf("migrated = %v, want %v", migrated, tt.migrated)
+			}
+			if len(file.Favorites) != tt.favorites {
+				t.Errorf("got %d favorites, want %d", len(file.Favorites), tt.favorites)
+			}
+		})
+	}
+}
+
+func TestMoveFavoriteInView(t *testing.T) {
+	albumsTagged := func(f LibraryFavorite) bool { return uriKind(f.URI) == "album" && f.HasTag("chill") }
+	tests := []struct {
+		name    string
+		uri     string
+		delta   int
+		visible func(LibraryFavorite) bool
+		want    []string
+		moved   bool
+	}{
+		{name: "steps over hidden favorites", uri: "spotify:album:a", delta: 1, visible: albumsTagged, want: []string{"x", "b", "p", "c", "a"}, moved: true},
+		{name: "steps up over hidden favorites", uri: "spotify:album:b", delta: -1, visible: albumsTagged, want: []string{"x", "b", "p", "c", "a"}, moved: true},
+		{name: "no visible neighbor", uri: "spotify:album:b", delta: 1, visible: albumsTagged, want: []string{"x", "a", "p", "c", "b"}},
+		{name: "not past the group", uri: "spotify:album:x", delta: 1, visible: func(LibraryFavorite) bool { return true }, want: []string{"x", "a", "p", "c", "b"}},
+		{name: "every favorite visible", uri: "spotify:playlist:p", delta: -1, visible: func(LibraryFavorite) bool { return true }, want: []string{"x", "p", "a", "c", "b"}, moved: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := openFavorites(filepath.Join(t.TempDir(), FAVORITES_FILE))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = store.AddAll([]LibraryFavorite{
+				{Title: "a", URI: "spotify:album:a", Tags: []string{"chill"}},
+				{Title: "p", URI: "spotify:playlist:p", Tags: []string{"chill"}},
+				{Title: "c", URI: "spotify:album:c"},
+				{Title: "b", URI: "spotify:album:b", Tags: []string{"chill"}},
+				{Title: "x", URI: "spotify:album:x", Tags: []string{"chill"}, Group: "Other"},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			moved, err := store.Move(tt.uri, tt.delta, tt.visible)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, favorite := range store.All() {
+				got = append(got, favorite.Title)
+			}
+			if moved != tt.moved || !slices.Equal(got, tt.want) {
+				t.Errorf("moved %v to %v, want %v to %v", moved, got, tt.moved, tt.want)
+			}
+		})
+	}
+}
+
+func TestFollowMovedFavorite(t *testing.T) {
+	pager := Paginator{}.Resize(3, 0).WithTotal(7) // Pages of 3, 3 and 1
+	rows := []LibraryItem{{}, {}, {}}
+	tests := []struct {
+		name       string
+		page       int
+		rows       []LibraryItem
+		cursor     int
+		delta      int
+		wantPage   int
+		wantCursor int
+	}{
+		{name: "within the page", page: 1, rows: rows, cursor: 1, delta: -1, wantPage: 1, wantCursor: 0},
+		{name: "up off the top row", page: 1, rows: rows, cursor: 0, delta: -1, wantPage: 0, wantCursor: 2},
+		{name: "down off the bottom row", page: 0, rows: rows, cursor: 2, delta: 1, wantPage: 1, wantCursor: 0},
+		{name: "up on the first page stays in range", page: 0, rows: rows, cursor: 0, delta: -1, wantPage: 0, wantCursor: 0},
+		{name: "down on the last page stays in range", page: 2, rows: rows[:1], cursor: 0, delta: 1, wantPage: 2, wantCursor: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := Model{pager: pager.Jump(tt.page), libraryList: tt.rows, cursor: tt.cursor}.followMovedFavorite(tt.delta)
+			if m.pager.Page() != tt.wantPage || m.cursor != tt.wantCursor {
+				t.Errorf("page %d row %d, want page %d row %d", m.pager.Page(), m.cursor, tt.wantPage, tt.wantCursor)
+			}
+		})
+	}
+}
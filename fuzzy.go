@@ -0,0 +1,221 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/rivo/uniseg"
+)
+
+// ==================================================
+// ===== fuzzy.go | Fuzzy search of the library =====
+// ==================================================
+
+// Scoring, loosely after fzf: every matched character scores, matches at the start of a word
+// or right after another match score extra, and skipped characters cost a little.
+const (
+	SCORE_MATCH          = 16
+	BONUS_BOUNDARY       = 8
+	BONUS_CONSECUTIVE    = 6
+	BONUS_FIRST_CHAR     = 2 // Multiplier on the bonus of the first character of a term
+	PENALTY_GAP_START    = 3
+	PENALTY_GAP_EXTENDED = 1
+)
+
+// libraryFilter narrows what the library shows. Both parts are optional.
+type libraryFilter struct {
+	tag    string // Only favorites with this tag
+	search string // Fuzzy search over name and artist
+}
+
+// librarySearchMsg is a ranked page of search results.
+type librarySearchMsg struct {
+	listDetail string
+	search     string
+	items      []LibraryItem
+	total      int
+}
+
+// fuzzyMatch matches a query against text. Each space separated term of the query has to match on its own,
+// with its characters appearing in order; the scores add up.
+//
+// Parameters:
+// - query: What was typed. Matching ignores case.
+// - text: The text to search.
+//
+// Returns:
+// - The score, higher is better.
+// - The indexes of the matched runes in text, in order.
+// - Whether every term matched.
+func fuzzyMatch(query, text string) (int, []int, bool) {
+	runes := foldRunes(text)
+	total := 0
+	matched := map[int]bool{}
+	for _, term := range strings.Fields(query) {
+		score, positions, ok := matchTerm(foldRunes(term), runes)
+		if !ok {
+			return 0, nil, false
+		}
+		total += score
+		for _, pos := range positions {
+			matched[pos] = true
+		}
+	}
+	positions := make([]int, 0, len(matched))
+	for pos := range matched {
+		positions = append(positions, pos)
+	}
+	sort.Ints(positions)
+	return total, positions, true
+}
+
+// foldRunes lowercases a string one rune at a time. strings.ToLower can change the number of runes
+// (İ becomes i and a combining dot), which would throw match positions off the original text.
+func foldRunes(str string) []rune {
+	runes := []rune(str)
+	for i, r := range runes {
+		runes[i] = unicode.ToLower(r)
+	}
+	return runes
+}
+
+// matchTerm finds the shortest window of text holding the term in order, then scores the match inside it.
+func matchTerm(term, text []rune) (int, []int, bool) {
+	if len(term) == 0 {
+		return 0, nil, true
+	}
+	// Forward: where does the first full match end?
+	end, t := -1, 0
+	for i, r := range text {
+		if r == term[t] {
+			t++
+			if t == len(term) {
+				end = i
+				break
+			}
+		}
+	}
+	if end < 0 {
+		return 0, nil, false
+	}
+	// Backward: the latest start that still matches, so the window is as tight as it gets.
+	start := end
+	for i, t := end, len(term)-1; i >= 0; i-- {
+		if text[i] == term[t] {
+			if t == 0 {
+				start = i
+				break
+			}
+			t--
+		}
+	}
+
+	score, positions := 0, make([]int, 0, len(term))
+	last := -1
+	t = 0
+	for i := start; i <= end && t < len(term); i++ {
+		if text[i] != term[t] {
+			continue
+		}
+		bonus := 0
+		if i == 0 || !unicode.IsLetter(text[i-1]) && !unicode.IsDigit(text[i-1]) {
+			bonus = BONUS_BOUNDARY
+		}
+		if last >= 0 {
+			if i == last+1 {
+				bonus = max(bonus, BONUS_CONSECUTIVE)
+			} else {
+				score -= PENALTY_GAP_START + (i-last-2)*PENALTY_GAP_EXTENDED
+			}
+		}
+		if t == 0 {
+			bonus *= BONUS_FIRST_CHAR
+		}
+		score += SCORE_MATCH + bonus
+		positions = append(positions, i)
+		last = i
+		t++
+	}
+	return score, positions, true
+}
+
+// searchLibrary ranks library items against a search, best first, and cuts out one page.
+// Names and artists are searched together, so "ok radiohead" finds OK Computer.
+//
+// Parameters:
+// - items: Every item the search covers.
+// - search: What was typed.
+// - pager: Which page of the results to return.
+//
+// Returns:
+// - The page of matching items, with their matched characters recorded for highlighting, counted in grapheme clusters.
+// - The number of matching items.
+func searchLibrary(items []LibraryItem, search string, pager Paginator) ([]LibraryItem, int) {
+	type result struct {
+		item  LibraryItem
+		score int
+	}
+	var results []result
+	for _, item := range items {
+		nameLen := len([]rune(item.name))
+		score, positions, ok := fuzzyMatch(search, item.name+" "+item.artist)
+		if !ok {
+			continue
+		}
+		var nameMatches, artistMatches []int
+		for _, pos := range positions {
+			if pos < nameLen {
+				nameMatches = append(nameMatches, pos)
+			} else if pos > nameLen {
+				artistMatches = append(artistMatches, pos-nameLen-1)
+			}
+		}
+		item.nameMatches, item.artistMatches = graphemeIndexes(item.name, nameMatches), graphemeIndexes(item.artist, artistMatches)
+		results = append(results, result{item: item, score: score})
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].score > results[j].score })
+
+	page, total := pageItems(pager, results, nil)
+	matches := make([]LibraryItem, 0, len(page))
+	for _, r := range page {
+		matches = append(matches, r.item)
+	}
+	return matches, total
+}
+
+// highlightMatches renders text with the characters (grapheme clusters) at positions in a highlight style.
+// Positions past the end are ignored.
+func highlightMatches(text string, positions []int, base, highlight lipgloss.Style) string {
+	if len(positions) == 0 {
+		return base.Render(text)
+	}
+	matched := make(map[int]bool, len(positions))
+	for _, pos := range positions {
+		matched[pos] = true
+	}
+	var out, run strings.Builder
+	inMatch := false
+	flush := func() {
+		if run.Len() == 0 {
+			return
+		}
+		if inMatch {
+			out.WriteString(highlight.Render(run.String()))
+		} else {
+			out.WriteString(base.Render(run.String()))
+		}
+		run.Reset()
+	}
+	graphemes := uniseg.NewGraphemes(text)
+	for i := 0; graphemes.Next(); i++ {
+		if matched[i] != inMatch {
+			flush()
+			inMatch = matched[i]
+		}
+		run.WriteString(graphemes.Str())
+	}
+	flush()
+	return out.String()
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestFuzzyMatch(t *testing.T) {
+	tests := []struct {
+		name          string
+		query         string
+		text          string
+		wantPositions []int
+		wantOK        bool
+	}{
+		{name: "prefix", query: "ok", text: "OK Computer", wantPositions: []int{0, 1}, wantOK: true},
+		{name: "ignores case", query: "COMP", text: "OK Computer", wantPositions: []int{3, 4, 5, 6}, wantOK: true},
+		{name: "tightest window", query: "rh", text: "Radiohead", wantPositions: []int{0, 5}, wantOK: true},
+		{name: "every term", query: "ok radiohead", text: "OK Computer Radiohead", wantPositions: []int{0, 1, 12, 13, 14, 15, 16, 17, 18, 19, 20}, wantOK: true},
+		{name: "letters that lowercase to two runes", query: "stan", text: "İİ Stan", wantPositions: []int{3, 4, 5, 6}, wantOK: true},
+		{name: "uppercase dotted I", query: "i", text: "İstanbul", wantPositions: []int{0}, wantOK: true},
+		{name: "out of order", query: "ba", text: "ab"},
+		{name: "one term missing", query: "ok zz", text: "OK Computer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, positions, ok := fuzzyMatch(tt.query, tt.text)
+			if ok != tt.wantOK || !slices.Equal(positions, tt.wantPositions) {
+				t.Errorf("got %v (%v), want %v (%v)", positions, ok, tt.wantPositions, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestFuzzyScoring(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		better string
+		worse  string
+	}{
+		{name: "word start beats middle", query: "com", better: "OK Computer", worse: "Sitcom"},
+		{name: "consecutive beats spread", query: "rad", better: "Radiohead", worse: "Reading Aid"},
+		{name: "short gap beats long gap", query: "ab", better: "a-b", worse: "a----b"},
+		{name: "first letter of a term counts double", query: "kid", better: "Kid A", worse: "Skid Row"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			better, _, _ := fuzzyMatch(tt.query, tt.better)
+			worse, _, _ := fuzzyMatch(tt.query, tt.worse)
+			if better <= worse {
+				t.Errorf("%q scored %d, not above %q with %d", tt.better, better, tt.worse, worse)
+			}
+		})
+	}
+}
+
+func TestSearchLibrary(t *testing.T) {
+	library := []LibraryItem{
+		{name: "Computer Love", artist: "Kraftwerk", uri: "spotify:album:love"},
+		{name: "OK Computer", artist: "Radiohead", uri: "spotify:album:ok"},
+		{name: "Kid A", artist: "Radiohead", uri: "spotify:album:kid"},
+	}
+	pager := Paginator{}.Resize(10, 0)
+	tests := []struct {
+		name   string
+		search string
+		want   []string
+	}{
+		{name: "best match first", search: "ok", want: []string{"spotify:album:ok", "spotify:album:love"}},
+		{name: "ties keep library order", search: "computer", want: []string{"spotify:album:love", "spotify:album:ok"}},
+		{name: "name and artist together", search: "kid radiohead", want: []string{"spotify:album:kid", "spotify:album:ok"}},
+		{name: "nothing matches", search: "zz", want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, total := searchLibrary(library, tt.search, pager)
+			got := []string{}
+			for _, item := range results {
+				got = append(got, item.uri)
+			}
+			if !slices.Equal(got, tt.want) || total != len(tt.want) {
+				t.Errorf("got %v of %d, want %v", got, total, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchLibraryMatches(t *testing.T) {
+	tests := []struct {
+		name       string
+		item       LibraryItem
+		search     string
+		wantName   []int
+		wantArtist []int
+	}{
+		{name: "name only", item: LibraryItem{name: "OK Computer", artist: "Radiohead"}, search: "comp", wantName: []int{3, 4, 5, 6}},
+		{name: "artist only", item: LibraryItem{name: "OK Computer", artist: "Radiohead"}, search: "head", wantArtist: []int{5, 6, 7, 8}},
+		{name: "split across both", item: LibraryItem{name: "OK Computer", artist: "Radiohead"}, search: "ok radio", wantName: []int{0, 1}, wantArtist: []int{0, 1, 2, 3, 4}},
+		{name: "artist after a wide name", item: LibraryItem{name: "東京", artist: "Tokyo"}, search: "東 tok", wantName: []int{0}, wantArtist: []int{0, 1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, _ := searchLibrary([]LibraryItem{tt.item}, tt.search, Paginator{}.Resize(10, 0))
+			if len(results) != 1 {
+				t.Fatalf("got %d results, want 1", len(results))
+			}
+			if !slices.Equal(results[0].nameMatches, tt.wantName) || !slices.Equal(results[0].artistMatches, tt.wantArtist) {
+				t.Errorf("name %v, artist %v, want %v, %v", results[0].nameMatches, results[0].artistMatches, tt.wantName, tt.wantArtist)
+			}
+		})
+	}
+}
@@ -6,7 +6,10 @@ require (
 	github.com/Treyson-Grange/go-moji-ui v1.0.2
 	github.com/charmbracelet/bubbletea v1.1.1
 	github.com/charmbracelet/lipgloss v0.13.1
+	github.com/godbus/dbus/v5 v5.1.0
 	github.com/joho/godotenv v1.5.1
+	github.com/rivo/uniseg v0.4.7
+	go.etcd.io/bbolt v1.3.11
 	golang.org/x/image v0.21.0
 	golang.org/x/term v0.25.0
 )
@@ -23,7 +26,6 @@ require (
 	github.com/muesli/ansi v0.0.0-20230316100256-276c6243b2f6 // indirect
 	github.com/muesli/cancelreader v0.2.2 // indirect
 	github.com/muesli/termenv v0.15.2 // indirect
-	github.com/rivo/uniseg v0.4.7 // indirect
 	golang.org/x/sync v0.8.0 // indirect
 	golang.org/x/sys v0.26.0 // indirect
 	golang.org/x/text v0.19.0 // indirect
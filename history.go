@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	bolt "go.etcd.io/bbolt"
+)
+
+// ==============================================================
+// ===== history.go | Local listening history and its stats =====
+// ==============================================================
+
+const HISTORY_DB = "history.db"
+const STATS_TOP = 5 // How many entries to show in each top list
+
+var listensBucket = []byte("listens")
+
+// History is the local listening history, stored in a bbolt database.
+type History struct {
+	db *bolt.DB
+}
+
+// statsPeriods are the windows the stats screen can show, in toggle order.
+var statsPeriods = []string{"week", "month"}
+
+// ListenStats is a summary of the listening history over a period.
+type ListenStats struct {
+	Period     string
+	Plays      int
+	Skips      int
+	ListenedMs int
+	TopTracks  []statsEntry
+	TopArtists []statsEntry
+	TopAlbums  []statsEntry
+}
+
+// statsEntry is one line of a top list.
+type statsEntry struct {
+	Name  string
+	Plays int
+}
+
+// openHistory opens (or creates) the history database.
+//
+// Parameters:
+// - path: Path to the database file.
+//
+// Returns:
+// - The opened history.
+// - An error if the database couldn't be opened, e.g. another JukeTUI holds the lock.
+func openHistory(path string) (*History, error) {
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(listensBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &History{db: db}, nil
+}
+
+// Close closes the history database.
+func (h *History) Close() error {
+	if h == nil {
+		return nil
+	}
+	return h.db.Close()
+}
+
+// historyKey makes a key that sorts listens by start time.
+func historyKey(l Listen) []byte {
+	return []byte(l.StartedAt.UTC().Format(time.RFC3339Nano) + "|" + l.URI)
+}
+
+// recordCmd returns a command that saves a finished listen.
+func (h *History) recordCmd(l *Listen) tea.Cmd {
+	if h == nil || l == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		data, err := json.Marshal(l)
+		if err != nil {
+			errorLogger.Println("Failed to marshal listen: ", err)
+			return nil
+		}
+		err = h.db.Update(func(tx *bolt.Tx) error {
+			return tx.Bucket(listensBucket).Put(historyKey(*l), data)
+		})
+		if err != nil {
+			errorLogger.Println("Failed to record listen: ", err)
+		}
+		return nil
+	}
+}
+
+// periodStart gets the start of the window for a stats period.
+func periodStart(period string, now time.Time) time.Time {
+	if period == "month" {
+		return now.AddDate(0, -1, 0)
+	}
+	return now.AddDate(0, 0, -7)
+}
+
+// statsCmd returns a command that computes listening stats for a period.
+func (h *History) statsCmd(period string) tea.Cmd {
+	if h == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		stats, err := h.stats(period, time.Now())
+		if err != nil {
+			errorLogger.Println("Failed to read listening history: ", err)
+		}
+		return stats
+	}
+}
+
+// stats summarizes every listen that started within a period.
+func (h *History) stats(period string, now time.Time) (ListenStats, error) {
+	stats := ListenStats{Period: period}
+	tracks, artists, albums := map[string]int{}, map[string]int{}, map[string]int{}
+	from := []byte(periodStart(period, now).UTC().Format(time.RFC3339Nano))
+
+	err := h.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(listensBucket).Cursor()
+		for key, value := cursor.Seek(from); key != nil; key, value = cursor.Next() {
+			var l Listen
+			if err := json.Unmarshal(value, &l); err != nil {
+				errorLogger.Printf("Skipping unreadable listen %s: %v", key, err)
+				continue
+			}
+			stats.Plays++
+			stats.ListenedMs += l.PlayedMs
+			if l.Skipped {
+				stats.Skips++
+				continue // Skips count towards the skip rate, not the top lists
+			}
+			tracks[l.Name+" - "+l.Artist()]++
+			for _, artist := range l.Artists {
+				artists[artist]++
+			}
+			if l.Album != "" {
+				albums[l.albumKey()]++
+			}
+		}
+		return nil
+	})
+
+	stats.TopTracks = topEntries(tracks)
+	stats.TopArtists = topEntries(artists)
+	stats.TopAlbums = topEntries(albums)
+	return stats, err
+}
+
+// lastPlayed gets when each context (album, playlist, ...) was last played from, for sorting the library.
+func (h *History) lastPlayed() (map[string]time.Time, error) {
+	played := map[string]time.Time{}
+	if h == nil {
+		return played, nil
+	}
+	err := h.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(listensBucket).ForEach(func(key, value []byte) error {
+			var l Listen
+			if err := json.Unmarshal(value, &l); err != nil || l.ContextURI == "" {
+				return nil
+			}
+			if l.StartedAt.After(played[l.ContextURI]) {
+				played[l.ContextURI] = l.StartedAt
+			}
+			return nil
+		})
+	})
+	return played, err
+}
+
+// albumKey names a listen's album with its artist, since different artists' albums often share a name.
+// Older listens have no album artist, so their first track artist stands in.
+func (l Listen) albumKey() string {
+	artist := l.AlbumArtist
+	if artist == "" && len(l.Artists) > 0 {
+		artist = l.Artists[0]
+	}
+	if artist == "" {
+		return l.Album
+	}
+	return l.Album + " - " + artist
+}
+
+// topEntries sorts play counts, most played first, and keeps the top few.
+func topEntries(counts map[string]int) []statsEntry {
+	entries := make([]statsEntry, 0, len(counts))
+	for name, plays := range counts {
+		entries = append(entries, statsEntry{Name: name, Plays: plays})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Plays != entries[j].Plays {
+			return entries[i].Plays > entries[j].Plays
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	if len(entries) > STATS_TOP {
+		entries = entries[:STATS_TOP]
+	}
+	return entries
+}
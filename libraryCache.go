@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ===============================================================
+// ===== libraryCache.go | Offline copy of the saved library =====
+// ===============================================================
+
+const (
+	LIBRARY_CACHE         = "library.json"
+	LIBRARY_CACHE_VERSION = 1
+	LIBRARY_SYNC_INTERVAL = 10 * time.Minute
+	LIBRARY_SYNC_RETRY    = time.Minute // Sooner when the last sync failed, so we come back online quickly
+)
+
+// libraryCacheFile is the on-disk layout of the cache.
+type libraryCacheFile struct {
+	Version   int                   `json:"version"`
+	SyncedAt  time.Time             `json:"synced_at"`
+	Albums    []SpotifyAlbumItem    `json:"albums"`
+	Playlists []SpotifyPlaylistItem `json:"playlists"`
+	Shows     []SpotifyShowItem     `json:"shows"`
+	Sort      map[string]string     `json:"sort,omitempty"` // Sort modes from before they moved to the settings, read once to carry them over
+}
+
+// LibraryCache holds the full saved albums, playlists and shows catalog, so pages render without the network.
+type LibraryCache struct {
+	path string
+	mu   sync.Mutex
+	data libraryCacheFile
+}
+
+// librarySyncedMsg reports the end of a background sync.
+type librarySyncedMsg struct {
+	changed bool
+	err     error
+}
+
+// librarySyncTickMsg asks for the next background sync.
+type librarySyncTickMsg struct{}
+
+// openLibraryCache loads the cache at path. A missing or unreadable cache starts empty and fills on the first sync.
+func openLibraryCache(path string) *LibraryCache {
+	cache := &LibraryCache{path: path, data: libraryCacheFile{Version: LIBRARY_CACHE_VERSION}}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			errorLogger.Println("Failed to read library cache: ", err)
+		}
+		return cache
+	}
+	var file libraryCacheFile
+	if err := json.Unmarshal(data, &file); err != nil || file.Version != LIBRARY_CACHE_VERSION {
+		errorLogger.Printf("Discarding library cache (version %d): %v", file.Version, err)
+		return cache
+	}
+	cache.data = file
+	return cache
+}
+
+// SyncedAt gets when the cache last synced successfully, or the zero time if it never has.
+func (c *LibraryCache) SyncedAt() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.data.SyncedAt
+}
+
+// legacySortModes gets the sort modes an older version kept in the cache, for the settings to take over.
+func (c *LibraryCache) legacySortModes() map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.data.Sort
+}
+
+// save writes the cache to disk. The caller holds c.mu through the write,
+// so overlapping syncs can't race and leave the older snapshot on disk.
+func (c *LibraryCache) save() error {
+	data, err := json.Marshal(c.data)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(c.path, data)
+}
+
+// page builds one library page from the cache in the library's sort order, leaving out favorites since they are listed separately.
+//
+// Parameters:
+// - listDetail: The library to page through (album, playlist or show).
+// - mode: The sort mode to page in.
+// - favorites: Favorites to leave out.
+// - pager: Which page to build.
+// - played: When each URI was last played, only needed when sorting by recently played.
+//
+// Returns:
+// - A SpotifyAlbum, SpotifyPlaylist or SpotifyShows whose Total is the number of non-favorites.
+func (c *LibraryCache) page(listDetail, mode string, favorites []LibraryFavorite, pager Paginator, played map[string]time.Time) tea.Msg {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	favoriteURIs := make(map[string]struct{}, len(favorites))
+	for _, favorite := range favorites {
+		favoriteURIs[favorite.URI] = struct{}{}
+	}
+	isFavorite := func(uri string) bool {
+		_, found := favoriteURIs[uri]
+		return found
+	}
+
+	if listDetail == "album" {
+		sorted := sortLibrary(c.data.Albums, mode, albumSortFields, played)
+		items, total := pageItems(pager, sorted, func(item SpotifyAlbumItem) bool { return isFavorite(item.Album.URI) })
+		albums := SpotifyAlbum{Total: total}
+		for _, item := range items {
+			albums.Items = append(albums.Items, struct{ SpotifyAlbumItem }{item})
+		}
+		return albums
+	}
+	if listDetail == "show" {
+		sorted := sortLibrary(c.data.Shows, mode, showSortFields, played)
+		items, total := pageItems(pager, sorted, func(item SpotifyShowItem) bool { return isFavorite(item.Show.URI) })
+		return SpotifyShows{Items: items, Total: total}
+	}
+	sorted := sortLibrary(c.data.Playlists, mode, playlistSortFields, played)
+	items, total := pageItems(pager, sorted, func(item SpotifyPlaylistItem) bool { return isFavorite(item.URI) })
+	return SpotifyPlaylist{Items: items, Total: total}
+}
+
+// items gets every cached album, playlist or show that isn't a favorite, as library rows.
+func (c *LibraryCache) items(listDetail string, favorites []LibraryFavorite) []LibraryItem {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	favoriteURIs := make(map[string]struct{}, len(favorites))
+	for _, favorite := range favorites {
+		favoriteURIs[favorite.URI] = struct{}{}
+	}
+	var items []LibraryItem
+	if listDetail == "album" {
+		for _, album := range c.data.Albums {
+			if _, found := favoriteURIs[album.Album.URI]; found {
+				continue
+			}
+			items = append(items, displayAlbum(album).libraryItem())
+		}
+		return items
+	}
+	if listDetail == "show" {
+		for _, show := range c.data.Shows {
+			if _, found := favoriteURIs[show.Show.URI]; !found {
+				items = append(items, displayShow(show.Show).libraryItem())
+			}
+		}
+		return items
+	}
+	for _, playlist := range c.data.Playlists {
+		if _, found := favoriteURIs[playlist.URI]; !found {
+			items = append(items, displayPlaylist(playlist).libraryItem())
+		}
+	}
+	return items
+}
+
+// syncCmd returns a command that brings the cache up to date in the background.
+func (c *LibraryCache) syncCmd(ctx context.Context, token string) tea.Cmd {
+	return func() tea.Msg {
+		changed, err := c.sync(ctx, token)
+		if err != nil {
+			errorLogger.Println("Library sync failed: ", err)
+		}
+		return librarySyncedMsg{changed: changed, err: err}
+	}
+}
+
+// syncTickCmd schedules the next sync, sooner if the last one failed.
+func syncTickCmd(failed bool) tea.Cmd {
+	delay := LIBRARY_SYNC_INTERVAL
+	if failed {
+		delay = LIBRARY_SYNC_RETRY
+	}
+	return tea.Tick(delay, func(time.Time) tea.Msg {
+		return librarySyncTickMsg{}
+	})
+}
+
+// sync fetches what changed since the last sync and saves the cache. Reports whether anything changed.
+func (c *LibraryCache) sync(ctx context.Context, token string) (bool, error) {
+	c.mu.Lock()
+	albums, playlists, shows := c.data.Albums, c.data.Playlists, c.data.Shows
+	c.mu.Unlock()
+
+	newAlbums, albumsChanged, err := syncAlbums(ctx, token, albums)
+	if err != nil {
+		return false, err
+	}
+	newPlaylists, playlistsChanged, err := syncPlaylists(ctx, token, playlists)
+	if err != nil {
+		return false, err
+	}
+	newShows, showsChanged, err := syncShows(ctx, token, shows)
+	if err != nil {
+		return false, err
+	}
+
+	c.mu.Lock()
+	c.data.Albums, c.data.Playlists, c.data.Shows = newAlbums, newPlaylists, newShows
+	c.data.SyncedAt = time.Now()
+	err = c.save()
+	c.mu.Unlock()
+	if err != nil {
+		errorLogger.Println("Failed to save library cache: ", err)
+	}
+	return albumsChanged || playlistsChanged || showsChanged, nil
+}
+
+// syncAlbums updates the cached saved albums. Saved albums come newest first by added_at,
+// so usually only the first page is needed: new albums are the ones before the first album we already have.
+// If the totals don't add up afterwards, something was removed or reordered and everything is fetched again.
+func syncAlbums(ctx context.Context, token string, cached []SpotifyAlbumItem) ([]SpotifyAlbumItem, bool, error) {
+	first, err := genericFetch[SpotifyPage[SpotifyAlbumItem]](ctx, "/me/albums", token, map[string]string{"limit": "50"}, nil)
+	if err != nil {
+		return cached, false, err
+	}
+
+	known := make(map[string]string, len(cached))
+	for _, item := range cached {
+		known[item.Album.URI] = item.AddedAt
+	}
+	var fresh []SpotifyAlbumItem
+	caughtUp := false
+	for _, item := range first.Items {
+		if addedAt, found := known[item.Album.URI]; found && addedAt == item.AddedAt {
+			caughtUp = true
+			break
+		}
+		fresh = append(fresh, item)
+	}
+	if caughtUp && len(fresh)+len(cached) == first.Total {
+		return append(fresh, cached...), len(fresh) > 0, nil
+	}
+
+	all, err := fetchAll[SpotifyAlbumItem](ctx, "/me/albums", token)
+	if err != nil {
+		return cached, false, err
+	}
+	return all, true, nil
+}
+
+// syncPlaylists updates the cached playlists. The list is cheap to fetch in full;
+// snapshot_id tells us whether any playlist changed since the last sync.
+func syncPlaylists(ctx context.Context, token string, cached []SpotifyPlaylistItem) ([]SpotifyPlaylistItem, bool, error) {
+	all, err := fetchAll[SpotifyPlaylistItem](ctx, "/me/playlists", token)
+	if err != nil {
+		return cached, false, err
+	}
+	if len(all) != len(cached) {
+		return all, true, nil
+	}
+	for i := range all {
+		if all[i].URI != cached[i].URI || all[i].SnapshotID != cached[i].SnapshotID {
+			return all, true, nil
+		}
+	}
+	return all, false, nil
+}
+
+// syncShows updates the cached shows. Like playlists, the list is fetched in full;
+// a new episode shows up as a change in the episode count.
+func syncShows(ctx context.Context, token string, cached []SpotifyShowItem) ([]SpotifyShowItem, bool, error) {
+	all, err := fetchAll[SpotifyShowItem](ctx, "/me/shows", token)
+	if err != nil {
+		return cached, false, err
+	}
+	if len(all) != len(cached) {
+		return all, true, nil
+	}
+	for i := range all {
+		if all[i].Show.URI != cached[i].Show.URI || all[i].Show.TotalEpisodes != cached[i].Show.TotalEpisodes {
+			return all, true, nil
+		}
+	}
+	return all, false, nil
+}
+
+// syncedAgo describes how long ago a sync happened, for the offline marker.
+func syncedAgo(t time.Time, now time.Time) string {
+	if t.IsZero() {
+		return "never synced"
+	}
+	switch elapsed := now.Sub(t); {
+	case elapsed < time.Minute:
+		return "synced just now"
+	case elapsed < time.Hour:
+		return fmt.Sprintf("synced %dm ago", int(elapsed.Minutes()))
+	case elapsed < 24*time.Hour:
+		return fmt.Sprintf("synced %dh ago", int(elapsed.Hours()))
+	default:
+		return "synced " + t.Format("Jan 2")
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// ========================================================
+// ===== librarySort.go | Sort orders for the library =====
+// ========================================================
+
+// Sort modes for the library. SORT_ADDED is the order Spotify returns, newest first for albums.
+const (
+	SORT_ADDED   = "added"
+	SORT_NAME    = "name"
+	SORT_ARTIST  = "artist"
+	SORT_RELEASE = "release"
+	SORT_TRACKS  = "tracks"
+	SORT_PLAYED  = "played"
+)
+
+// librarySorts are the sort modes each library supports, in toggle order.
+var librarySorts = map[string][]string{
+	"album":    {SORT_ADDED, SORT_NAME, SORT_ARTIST, SORT_RELEASE, SORT_TRACKS, SORT_PLAYED},
+	"playlist": {SORT_ADDED, SORT_NAME, SORT_ARTIST, SORT_TRACKS, SORT_PLAYED},
+	"show":     {SORT_ADDED, SORT_NAME, SORT_ARTIST, SORT_TRACKS, SORT_PLAYED},
+}
+
+// sortFields are the parts of an album or playlist the sort modes compare.
+type sortFields struct {
+	name     string
+	artist   string
+	uri      string
+	released string // YYYY, YYYY-MM or YYYY-MM-DD, which sort correctly as strings
+	tracks   int
+}
+
+// albumSortFields gets the sort fields of a saved album.
+func albumSortFields(item SpotifyAlbumItem) sortFields {
+	artist := ""
+	if len(item.Album.Artists) > 0 {
+		artist = item.Album.Artists[0].Name
+	}
+	return sortFields{name: item.Album.Name, artist: artist, uri: item.Album.URI, released: item.Album.ReleaseDate, tracks: item.Album.TotalTracks}
+}
+
+// playlistSortFields gets the sort fields of a playlist. The owner stands in for the artist.
+func playlistSortFields(item SpotifyPlaylistItem) sortFields {
+	return sortFields{name: item.Name, artist: item.Owner.DisplayName, uri: item.URI, tracks: item.Tracks.Total}
+}
+
+// showSortFields gets the sort fields of a saved show. The publisher stands in for the artist, episodes for tracks.
+func showSortFields(item SpotifyShowItem) sortFields {
+	return sortFields{name: item.Show.Name, artist: item.Show.Publisher, uri: item.Show.URI, tracks: item.Show.TotalEpisodes}
+}
+
+// nextSort gets the sort mode after current for a library, wrapping around.
+func nextSort(listDetail, current string) string {
+	modes := librarySorts[listDetail]
+	for i, mode := range modes {
+		if mode == current {
+			return modes[(i+1)%len(modes)]
+		}
+	}
+	return SORT_ADDED
+}
+
+// sortLabel describes a sort mode for the library header. Playlists have no added date, only Spotify's order.
+func sortLabel(listDetail, mode string) string {
+	if mode == SORT_ADDED && listDetail == "playlist" {
+		return "default order"
+	}
+	return "by " + mode
+}
+
+// sortLibrary returns a sorted copy of a library. Ties keep Spotify's order.
+//
+// Parameters:
+// - items: The library, in Spotify's order.
+// - mode: The sort mode.
+// - fields: Gets the sort fields of an item.
+// - played: When each URI was last played, for SORT_PLAYED. Never played items go last.
+//
+// Returns:
+// - The sorted library. With SORT_ADDED (or an unknown mode) it is items itself.
+//
+// Type parameters:
+// - T: The type of one item.
+func sortLibrary[T any](items []T, mode string, fields func(T) sortFields, played map[string]time.Time) []T {
+	var less func(a, b sortFields) bool
+	switch mode {
+	case SORT_NAME:
+		less = func(a, b sortFields) bool { return strings.ToLower(a.name) < strings.ToLower(b.name) }
+	case SORT_ARTIST:
+		less = func(a, b sortFields) bool {
+			if !strings.EqualFold(a.artist, b.artist) {
+				return strings.ToLower(a.artist) < strings.ToLower(b.artist)
+			}
+			return strings.ToLower(a.name) < strings.ToLower(b.name)
+		}
+	case SORT_RELEASE:
+		less = func(a, b sortFields) bool { return a.released > b.released }
+	case SORT_TRACKS:
+		less = func(a, b sortFields) bool { return a.tracks > b.tracks }
+	case SORT_PLAYED:
+		less = func(a, b sortFields) bool { return played[a.uri].After(played[b.uri]) }
+	default:
+		return items
+	}
+
+	keys := make([]sortFields, len(items))
+	for i, item := range items {
+		keys[i] = fields(item)
+	}
+	order := make([]int, len(items))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return less(keys[order[i]], keys[order[j]]) })
+
+	sorted := make([]T, len(items))
+	for i, index := range order {
+		sorted[i] = items[index]
+	}
+	return sorted
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// ==================================================================
+// ===== listens.go | Turn playback polling into discrete plays =====
+// ==================================================================
+
+const SKIP_THRESHOLD_MS = 10000 // A track left with more than this remaining counts as skipped
+const SEEK_SLACK_MS = 1500      // Progress allowed to run ahead of wall time before we call it a seek
+
+// Listen is a single play of a track, as observed through successive PlaybackState polls.
+type Listen struct {
+	URI         string    `json:"uri"`
+	Name        string    `json:"name"`
+	Artists     []string  `json:"artists"`
+	Album       string    `json:"album"`
+	AlbumArtist string    `json:"album_artist,omitempty"` // Keeps albums sharing a name apart, empty in older listens
+	ContextURI  string    `json:"context_uri"`
+	DurationMs  int       `json:"duration_ms"`
+	StartedAt   time.Time `json:"started_at"`
+	PlayedMs    int       `json:"played_ms"`
+	Skipped     bool      `json:"skipped"`
+}
+
+// Artist returns the listen's artists joined for display.
+func (l Listen) Artist() string {
+	return strings.Join(l.Artists, ", ")
+}
+
+// listenTracker watches playback states and reports when a listen starts and ends.
+type listenTracker struct {
+	current      *Listen
+	lastProgress int
+	lastPlaying  bool
+	lastSeen     time.Time
+}
+
+// newListen creates a listen for the track in a playback state.
+func newListen(state PlaybackState, now time.Time) *Listen {
+	display := state.display()
+	albumArtist := ""
+	if len(state.Item.Album.Artists) > 0 {
+		albumArtist = state.Item.Album.Artists[0].Name
+	}
+	return &Listen{
+		URI:         state.Item.URI,
+		Name:        display.Title,
+		Artists:     display.Artists,
+		Album:       display.Subtitle,
+		AlbumArtist: albumArtist,
+		ContextURI:  state.Context.URI,
+		DurationMs:  state.Item.DurationMs,
+		StartedAt:   now.Add(-time.Duration(state.ProgressMs) * time.Millisecond),
+	}
+}
+
+// observe feeds a new playback state to the tracker.
+//
+// Parameters:
+// - state: The latest playback state from the API.
+// - now: The time the state was received.
+//
+// Returns:
+// - started: The listen that began with this state, if any.
+// - finished: The listen that ended with this state, if any.
+func (t *listenTracker) observe(state PlaybackState, now time.Time) (started, finished *Listen) {
+	if state.Item.URI == "" { // Failed fetch or nothing playing, wait for real data
+		return nil, nil
+	}
+
+	if t.current != nil && t.current.URI == state.Item.URI {
+		delta := state.ProgressMs - t.lastProgress
+		wall := int(now.Sub(t.lastSeen).Milliseconds())
+		switch {
+		case delta >= 0:
+			// Only time we actually heard counts, so seeking forward doesn't inflate the play
+			if t.lastPlaying || state.IsPlaying {
+				t.current.PlayedMs += min(delta, wall+SEEK_SLACK_MS)
+			}
+		case t.lastProgress > state.Item.DurationMs-SKIP_THRESHOLD_MS && state.ProgressMs < SKIP_THRESHOLD_MS:
+			// Wrapped around to the start: the track is on repeat
+			finished = t.finish(false)
+			started = newListen(state, now)
+			t.current = started
+		}
+		t.track(state, now)
+		return started, finished
+	}
+
+	if t.current != nil {
+		finished = t.finish(t.lastProgress < t.current.DurationMs-SKIP_THRESHOLD_MS)
+	}
+	started = newListen(state, now)
+	t.current = started
+	t.track(state, now)
+	return started, finished
+}
+
+// track remembers where the last observation left off.
+func (t *listenTracker) track(state PlaybackState, now time.Time) {
+	t.lastProgress = state.ProgressMs
+	t.lastPlaying = state.IsPlaying
+	t.lastSeen = now
+}
+
+// finish closes out the current listen and returns a copy of it.
+func (t *listenTracker) finish(skipped bool) *Listen {
+	done := *t.current
+	done.Skipped = skipped
+	done.PlayedMs = min(done.PlayedMs, done.DurationMs)
+	t.current = nil
+	return &done
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// listenStep is one poll fed to a listenTracker, with what it should report.
+type listenStep struct {
+	state    PlaybackState
+	now      int    // Milliseconds after clockStart
+	started  string // URI of the listen that starts, if any
+	finished string // The listen that finishes, as "uri played N skipped B", if any
+}
+
+// describeListen formats a finished listen for comparison.
+func describeListen(l *Listen) string {
+	if l == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s played %d skipped %v", l.URI, l.PlayedMs, l.Skipped)
+}
+
+func TestListenTrackerObserve(t *testing.T) {
+	const a, b = "spotify:track:a", "spotify:track:b"
+	tests := []struct {
+		name  string
+		steps []listenStep
+	}{
+		{name: "played to the end", steps: []listenStep{
+			{state: playing(a, 0, true, 0), now: 0, started: a},
+			{state: playing(a, 30000, true, 0), now: 30000},
+			{state: playing(a, 59000, true, 0), now: 59000},
+			{state: playing(b, 1000, true, 0), now: 61000, started: b, finished: a + " played 59000 skipped false"},
+		}},
+		{name: "skipped", steps: []listenStep{
+			{state: playing(a, 0, true, 0), now: 0, started: a},
+			{state: playing(a, 20000, true, 0), now: 20000},
+			{state: playing(b, 0, true, 0), now: 20500, started: b, finished: a + " played 20000 skipped true"},
+		}},
+		{name: "left just inside the skip threshold", steps: []listenStep{
+			{state: playing(a, 0, true, 0), now: 0, started: a},
+			{state: playing(a, 60000-SKIP_THRESHOLD_MS+1, true, 0), now: 60000 - SKIP_THRESHOLD_MS + 1},
+			{state: playing(b, 0, true, 0), now: 60000 - SKIP_THRESHOLD_MS + 2, started: b, finished: fmt.Sprintf("%s played %d skipped false", a, 60000-SKIP_THRESHOLD_MS+1)},
+		}},
+		{name: "seeking ahead only counts the time heard", steps: []listenStep{
+			{state: playing(a, 0, true, 0), now: 0, started: a},
+			{state: playing(a, 40000, true, 0), now: 2000},
+			{state: playing(a, 59000, true, 0), now: 21000},
+			{state: playing(b, 0, true, 0), now: 22000, started: b, finished: fmt.Sprintf("%s played %d skipped false", a, 2000+SEEK_SLACK_MS+19000)},
+		}},
+		{name: "seeking back doesn't end the listen", steps: []listenStep{
+			{state: playing(a, 0, true, 0), now: 0, started: a},
+			{state: playing(a, 30000, true, 0), now: 30000},
+			{state: playing(a, 20000, true, 0), now: 31000},
+			{state: playing(a, 30000, true, 0), now: 41000},
+			{state: playing(b, 0, true, 0), now: 42000, started: b, finished: a + " played 40000 skipped true"},
+		}},
+		{name: "on repeat starts a new listen", steps: []listenStep{
+			{state: playing(a, 0, true, 0), now: 0, started: a},
+			{state: playing(a, 58000, true, 0), now: 58000},
+			{state: playing(a, 1000, true, 0), now: 61000, started: a, finished: a + " played 58000 skipped false"},
+			{state: playing(b, 0, true, 0), now: 70000, started: b, finished: a + " played 0 skipped true"}, // No later poll of the repeat, so nothing heard yet
+		}},
+		{name: "time paused doesn't count", steps: []listenStep{
+			{state: playing(a, 10000, true, 0), now: 0, started: a},
+			{state: playing(a, 20000, false, 0), now: 10000},
+			{state: playing(a, 20000, false, 0), now: 60000},
+			{state: playing(a, 25000, true, 0), now: 65000},
+			{state: playing(b, 0, true, 0), now: 66000, started: b, finished: a + " played 15000 skipped true"},
+		}},
+		{name: "empty polls are ignored", steps: []listenStep{
+			{state: playing(a, 0, true, 0), now: 0, started: a},
+			{state: PlaybackState{}, now: 5000},
+			{state: playing(a, 10000, true, 0), now: 10000},
+			{state: playing(b, 0, true, 0), now: 11000, started: b, finished: a + " played 10000 skipped true"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := &listenTracker{}
+			for i, step := range tt.steps {
+				started, finished := tracker.observe(step.state, at(step.now))
+				startedURI := ""
+				if started != nil {
+					startedURI = started.URI
+				}
+				if startedURI != step.started {
+					t.Errorf("step %d: started %q, want %q", i, startedURI, step.started)
+				}
+				if got := describeListen(finished); got != step.finished {
+					t.Errorf("step %d: finished %q, want %q", i, got, step.finished)
+				}
+			}
+		})
+	}
+}
+
+func TestListenStartedAt(t *testing.T) {
+	started, _ := (&listenTracker{}).observe(playing("spotify:track:a", 12000, true, 0), at(20000))
+	if want := at(8000); !started.StartedAt.Equal(want) {
+		t.Errorf("started at %v, want %v", started.StartedAt, want)
+	}
+}
@@ -4,8 +4,6 @@ import (
 	"io"
 	"log"
 	"os"
-
-	"github.com/joho/godotenv"
 )
 
 // ============================================
@@ -15,10 +13,7 @@ import (
 // errorLogger logs errors to the error.log file.
 // It is used to log errors that occur during the execution of the program.
 var errorLogger = func() *log.Logger {
-	err := godotenv.Load(".env")
-	if err != nil {
-		log.Fatalf("Error loading .env file: %v", err)
-	}
+	loadEnv()
 	if os.Getenv("DEVELOPMENT") == "true" {
 		file, err := os.OpenFile("errors.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
 		if err != nil {
@@ -33,10 +28,7 @@ var errorLogger = func() *log.Logger {
 // infoLogger is a logger that writes to a file called info.log.
 // It is used to log non-error information, such as successful operations.
 var infoLogger = func() *log.Logger {
-	err := godotenv.Load(".env")
-	if err != nil {
-		log.Fatalf("Error loading .env file: %v", err)
-	}
+	loadEnv()
 	if os.Getenv("DEVELOPMENT") == "true" {
 		file, err := os.OpenFile("info.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
 		if err != nil {
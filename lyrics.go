@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// =========================================================
+// ===== lyrics.go | Find, parse and sync track lyrics =====
+// =========================================================
+
+const LRCLIB_URL = "https://lrclib.net"
+
+// errNoLyrics is returned by a provider that has nothing for a track.
+var errNoLyrics = errors.New("no lyrics found")
+
+// lrcTimestamp matches one [mm:ss.xx] tag at the start of an LRC line.
+var lrcTimestamp = regexp.MustCompile(`^\[(\d+):(\d{1,2})(?:[.:](\d{1,3}))?\]`)
+
+// lyricsQuery is what providers get to look a track up by.
+type lyricsQuery struct {
+	URI        string
+	Title      string
+	Artist     string
+	Album      string
+	DurationMs int
+}
+
+// lyricsProvider is a source of LRC (or plain text) lyrics.
+type lyricsProvider interface {
+	name() string
+	find(ctx context.Context, q lyricsQuery) (string, error)
+}
+
+// Lyrics are the parsed lyrics for one track, sent to Update once resolved.
+type Lyrics struct {
+	URI    string
+	Lines  []lyricLine
+	Synced bool
+}
+
+// lyricLine is one line of lyrics and when it starts. TimeMs is unused for unsynced lyrics.
+type lyricLine struct {
+	TimeMs int
+	Text   string
+}
+
+// LyricsFinder tries each provider in order, caching what it finds on disk.
+type LyricsFinder struct {
+	providers []lyricsProvider
+}
+
+// newLyricsFinder builds a finder from the environment. Local files are tried before the network.
+func newLyricsFinder() *LyricsFinder {
+	var providers []lyricsProvider
+	if dir := os.Getenv("LYRICS_DIR"); dir != "" {
+		providers = append(providers, &localLyrics{dir: dir})
+	}
+	if base := queryEnv("LRCLIB_URL", LRCLIB_URL); base != "none" {
+		providers = append(providers, &lrclib{baseURL: strings.TrimSuffix(base, "/")})
+	}
+	return &LyricsFinder{providers: providers}
+}
+
+// newLyricsQuery builds a query for the track in a playback state.
+func newLyricsQuery(state PlaybackState) lyricsQuery {
+	display := state.display()
+	if display.Kind == "episode" { // Podcasts have no lyrics
+		return lyricsQuery{}
+	}
+	artist := ""
+	if len(display.Artists) > 0 {
+		artist = display.Artists[0]
+	}
+	return lyricsQuery{
+		URI:        display.URI,
+		Title:      display.Title,
+		Artist:     artist,
+		Album:      display.Subtitle,
+		DurationMs: display.DurationMs,
+	}
+}
+
+// findCmd returns a command that resolves lyrics for a track.
+//
+// Parameters:
+// - ctx: Cancels the lookup.
+// - q: The track to look up.
+//
+// Returns:
+// - A command returning Lyrics, with no lines if nothing was found.
+func (f *LyricsFinder) findCmd(ctx context.Context, q lyricsQuery) tea.Cmd {
+	if f == nil || q.URI == "" {
+		return nil
+	}
+	return func() tea.Msg {
+		cache := cachePath(filepath.Join("lyrics", fmt.Sprintf("%x.lrc", sha1.Sum([]byte(q.URI)))))
+		if data, err := os.ReadFile(cache); err == nil {
+			return parseLRC(q.URI, string(data))
+		}
+		for _, provider := range f.providers {
+			text, err := provider.find(ctx, q)
+			if err != nil {
+				if !errors.Is(err, errNoLyrics) {
+					errorLogger.Printf("Lyrics provider %s failed: %v", provider.name(), err)
+				}
+				continue
+			}
+			if err := writeFileAtomic(cache, []byte(text)); err != nil {
+				errorLogger.Println("Failed to cache lyrics: ", err)
+			}
+			return parseLRC(q.URI, text)
+		}
+		return Lyrics{URI: q.URI}
+	}
+}
+
+// parseLRC parses LRC text into lines sorted by time. Text without any timestamps is kept as unsynced lyrics.
+func parseLRC(uri, text string) Lyrics {
+	lyrics := Lyrics{URI: uri}
+	var plain []lyricLine
+	for _, raw := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		line := strings.TrimSpace(raw)
+		var times []int
+		for {
+			match := lrcTimestamp.FindStringSubmatch(line)
+			if match == nil {
+				break
+			}
+			minutes, _ := strconv.Atoi(match[1])
+			seconds, _ := strconv.Atoi(match[2])
+			fraction := 0
+			if match[3] != "" {
+				fraction, _ = strconv.Atoi(match[3])
+				for i := len(match[3]); i < 3; i++ { // .5 and .50 are both half a second
+					fraction *= 10
+				}
+			}
+			times = append(times, minutes*60000+seconds*1000+fraction)
+			line = strings.TrimSpace(line[len(match[0]):])
+		}
+		if len(times) == 0 {
+			if line != "" && !strings.HasPrefix(line, "[") { // Skip metadata tags like [ar:Artist]
+				plain = append(plain, lyricLine{Text: line})
+			}
+			continue
+		}
+		for _, t := range times {
+			lyrics.Lines = append(lyrics.Lines, lyricLine{TimeMs: t, Text: line})
+		}
+	}
+
+	if len(lyrics.Lines) == 0 {
+		lyrics.Lines = plain
+		return lyrics
+	}
+	lyrics.Synced = true
+	sort.SliceStable(lyrics.Lines, func(i, j int) bool { return lyrics.Lines[i].TimeMs < lyrics.Lines[j].TimeMs })
+	return lyrics
+}
+
+// currentLine gets the index of the line being sung at a point in the track, or -1 before the first line.
+func (l Lyrics) currentLine(progressMs int) int {
+	if !l.Synced {
+		return -1
+	}
+	return sort.Search(len(l.Lines), func(i int) bool { return l.Lines[i].TimeMs > progressMs }) - 1
+}
+
+// ==================
+// ===== LRCLIB =====
+// ==================
+
+// lrclib looks lyrics up on an LRCLIB compatible API.
+type lrclib struct {
+	baseURL string
+}
+
+func (p *lrclib) name() string { return "lrclib" }
+
+func (p *lrclib) find(ctx context.Context, q lyricsQuery) (string, error) {
+	params := url.Values{}
+	params.Set("track_name", q.Title)
+	params.Set("artist_name", q.Artist)
+	params.Set("album_name", q.Album)
+	params.Set("duration", strconv.Itoa(q.DurationMs/1000))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+"/api/get?"+params.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", "JukeTUI (https://github.com/Treyson-Grange/JukeTUI)")
+
+	resp, err := webClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", errNoLyrics
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		SyncedLyrics string `json:"syncedLyrics"`
+		PlainLyrics  string `json:"plainLyrics"`
+		Instrumental bool   `json:"instrumental"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	switch {
+	case result.SyncedLyrics != "":
+		return result.SyncedLyrics, nil
+	case result.PlainLyrics != "":
+		return result.PlainLyrics, nil
+	case result.Instrumental:
+		return "[00:00.00]♪ Instrumental ♪", nil
+	}
+	return "", errNoLyrics
+}
+
+// =======================
+// ===== Local files =====
+// =======================
+
+// localLyrics looks for .lrc files in a directory, named "Artist - Title.lrc" or "Title.lrc".
+type localLyrics struct {
+	dir string
+}
+
+func (p *localLyrics) name() string { return "local" }
+
+func (p *localLyrics) find(_ context.Context, q lyricsQuery) (string, error) {
+	entries, err := os.ReadDir(p.dir)
+	if err != nil {
+		return "", err
+	}
+	wanted := []string{
+		strings.ToLower(q.Artist + " - " + q.Title + ".lrc"),
+		strings.ToLower(q.Title + ".lrc"),
+	}
+	for _, name := range wanted {
+		for _, entry := range entries {
+			if strings.ToLower(entry.Name()) == name {
+				data, err := os.ReadFile(filepath.Join(p.dir, entry.Name()))
+				if err != nil {
+					return "", err
+				}
+				return string(data), nil
+			}
+		}
+	}
+	return "", errNoLyrics
+}
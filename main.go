@@ -4,14 +4,14 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"math"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
-	"github.com/joho/godotenv"
 	"golang.org/x/term"
 )
 
@@ -19,73 +19,251 @@ import (
 // ===== main.go | Entry point and loop =====
 // ==========================================
 
-func initialModel(token, listDetail string, favorites []LibraryFavorite) Model {
+func initialModel(token, listDetail string, favoriteStore *FavoritesStore, libraryCache *LibraryCache, history *History, nowPlaying *NowPlayingExporter) Model {
 	_, height, err := term.GetSize(int(os.Stdout.Fd()))
 	if err != nil {
 		log.Fatalf("Failed to get terminal size: %v", err)
 	}
 
-	return Model{
-		token:      token,
-		listDetail: listDetail,
-		height:     height,
-		favorites:  favorites,
+	ctx, cancel := context.WithCancel(context.Background())
+	m := Model{
+		ctx:           ctx,
+		cancel:        cancel,
+		token:         token,
+		listDetail:    listDetail,
+		height:        height,
+		favorites:     favoriteStore.All(),
+		favoriteStore: favoriteStore,
+		libraryCache:  libraryCache,
+		settings:      loadSettings(libraryCache.legacySortModes()),
+		listens:       &listenTracker{},
+		scrobbler:     newScrobbler(),
+		history:       history,
+		nowPlaying:    nowPlaying,
+		lyricsFinder:  newLyricsFinder(),
+		notifier:      newNotifier(),
 	}
+	m.pager = m.libraryPager()
+	return m
 }
 
 var keybinds = map[string]string{}
 
-const FETCH_TIMER = 2
+// LIBRARY_VIEWS are the libraries the library pane can show, in switching order.
+var LIBRARY_VIEWS = []string{"album", "playlist", "show", "favorites"}
 
 func (m Model) Init() tea.Cmd {
 	return tea.Batch(
-		handleFetchPlayback(m.token),
-		handleGetLibraryTotal(m.token, m.listDetail),
-		scheduleProgressInc(1*time.Second),
-		handleFetchLibrary(m.favorites, m.token, m.listDetail, m.height-LIBRARY_SPACING-len(m.favorites), 0),
-		handleGetQueue(m.token),
+		tickCmd(0), // Polls playback straight away
+		libraryCmd(m.ctx, m.libraryFetch, handleFetchLibrary(m.libraryCache, m.history, m.favorites, m.listDetail, m.settings.SortMode(m.listDetail), m.libraryFilter(), m.pager)),
+		handleGetQueue(m.ctx, m.token),
+		m.scrobbler.flushJournalCmd(m.ctx),
+		m.libraryCache.syncCmd(m.ctx, m.token),
+		pruneCoversCmd(),
 	)
 }
 
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
+	case tea.MouseMsg:
+		return m.updateMouse(msg)
+
 	case tea.KeyMsg:
+		if m.prompt.kind != "" {
+			return m.updatePrompt(msg)
+		}
 		switch strings.ToLower(msg.String()) {
 		case keybinds["Quit"]:
+			m.cancel() // Abandon whatever is still in flight
 			return m, tea.Quit
 
 		case keybinds["Play/Pause"]:
-			if m.state.IsPlaying {
-				handleGenericPut("/me/player/pause", m.token, nil, nil)
+			return m.togglePlay()
+
+		case keybinds["Skip"]:
+			return m.skip()
+
+		case keybinds["Shuffle"]:
+			return m.toggleShuffle()
+
+		case keybinds["Favorites"]:
+			if len(m.libraryList) == 0 {
+				return m, nil
+			}
+			item := m.libraryList[m.cursor]
+			var err error
+			if m.favoriteStore.Contains(item.uri) {
+				err = m.favoriteStore.Remove(item.uri)
 			} else {
-				handleGenericPut("/me/player/play", m.token, nil, map[string]string{"device_id": m.state.Device.ID})
+				favorite := LibraryFavorite{Title: item.name, Author: item.artist, URI: item.uri}
+				if m.artist != nil { // Artist page rows describe the row, not who made it
+					favorite.Author = map[bool]string{true: "", false: m.artist.Artist.Name}[uriKind(item.uri) == "artist"]
+				}
+				if m.show != nil { // Episode rows show how far along they are, the show's publisher made them
+					favorite.Author = m.show.Show.artist
+				}
+				err = m.favoriteStore.Add(favorite)
+			}
+			if err != nil {
+				return m.notifyErr("Failed to update favorites", err), nil
+			}
+			return m.notifyFavorite(item.uri, item.name).reloadLibrary()
+
+		case keybinds["Favorite Playing"]:
+			if m.state.Item.URI == "" {
+				return m, nil
+			}
+			var err error
+			if m.favoriteStore.Contains(m.state.Item.URI) {
+				err = m.favoriteStore.Remove(m.state.Item.URI)
+			} else {
+				display := m.state.display()
+				err = m.favoriteStore.Add(LibraryFavorite{Title: display.Title, Author: display.FirstArtist(), URI: display.URI})
+			}
+			if err != nil {
+				return m.notifyErr("Failed to update favorites", err), nil
+			}
+			return m.notifyFavorite(m.state.Item.URI, m.state.display().Title).reloadLibrary()
+
+		case keybinds["Switch Library"]:
+			m.artist, m.show = nil, nil
+			next := LIBRARY_VIEWS[0]
+			for i, detail := range LIBRARY_VIEWS {
+				if detail == m.listDetail {
+					next = LIBRARY_VIEWS[(i+1)%len(LIBRARY_VIEWS)]
+				}
+			}
+			m.listDetail = next
+			m.pager, m.cursor, m.loading = Paginator{}, 0, true
+			m.libraryList = nil
+			return m.reloadLibrary()
+
+		case keybinds["Edit Tags"], keybinds["Edit Group"]:
+			if len(m.libraryList) == 0 || !m.libraryList[m.cursor].favorite {
+				return m, nil
+			}
+			for _, favorite := range m.favorites {
+				if favorite.URI != m.libraryList[m.cursor].uri {
+					continue
+				}
+				if strings.ToLower(msg.String()) == keybinds["Edit Tags"] {
+					return m.openPrompt(PROMPT_TAGS, strings.Join(favorite.Tags, ", ")), nil
+				}
+				return m.openPrompt(PROMPT_GROUP, favorite.Group), nil
 			}
 			return m, nil
 
-		case keybinds["Skip"]:
-			handleGenericPost("/me/player/next", m.token, nil, nil)
+		case keybinds["Move Up"], keybinds["Move Down"]:
+			if len(m.libraryList) == 0 || !m.libraryList[m.cursor].favorite || m.artist != nil || m.show != nil || m.search != "" { // Only the library shows favorites in their order
+				return m, nil
+			}
+			delta := 1
+			if strings.ToLower(msg.String()) == keybinds["Move Up"] {
+				delta = -1
+			}
+			moved, err := m.favoriteStore.Move(m.libraryList[m.cursor].uri, delta, m.favoriteVisible)
+			if err != nil {
+				return m.notifyErr("Failed to move favorite", err), nil
+			}
+			if !moved {
+				return m, nil
+			}
+			return m.followMovedFavorite(delta).reloadLibrary()
+
+		case keybinds["Filter Tag"]:
+			m.tagFilter = nextTag(m.favoriteStore.Tags(), m.tagFilter)
+			m.cursor, m.pager = 0, m.pager.First()
+			return m.reloadLibrary()
+
+		case keybinds["Search"]:
+			m.prompt = libraryPrompt{kind: PROMPT_SEARCH, value: m.search}
 			return m, nil
 
-		case keybinds["Shuffle"]:
-			handleGenericPut("/me/player/shuffle", m.token, map[string]string{"state": fmt.Sprintf("%t", !m.state.ShuffleState)}, nil)
+		case keybinds["Back"]:
+			if m.showMessages {
+				m.showMessages = false
+				return m, nil
+			}
+			if m.artist != nil {
+				return m.showArtist(m.artist.parent)
+			}
+			if m.show != nil {
+				return m.closeShow()
+			}
+			if m.search == "" {
+				return m, nil
+			}
+			m.search, m.cursor = "", 0
+			m.pager = m.pager.First()
+			return m.reloadLibrary()
+
+		case keybinds["Artist"]:
+			if len(m.libraryList) == 0 {
+				return m, nil
+			}
+			return m.openArtist(m.libraryList[m.cursor].uri, m.artist)
+
+		case keybinds["Playing Artist"]:
+			if len(m.state.Item.Artists) == 0 {
+				return m, nil
+			}
+			return m.openArtist(m.state.Item.Artists[0].URI, m.artist)
+
+		case keybinds["Queue Artist"]:
+			start := m.queueStart() // The top row of the queue pane, as scrolled
+			if start >= len(m.queue.Queue) || len(m.queue.Queue[start].Artists) == 0 {
+				return m, nil
+			}
+			return m.openArtist(m.queue.Queue[start].Artists[0].URI, m.artist)
+
+		case keybinds["Follow"]:
+			if m.artist == nil {
+				return m, nil
+			}
+			page := *m.artist
+			page.Following = !page.Following
+			m.artist = &page
+			return m, followCmd(m.ctx, m.token, page.Artist, page.Following)
+
+		case keybinds["Sort"]:
+			if m.listDetail == "favorites" { // Favorites keep the order you give them
+				return m, nil
+			}
+			if err := m.settings.SetSortMode(m.listDetail, nextSort(m.listDetail, m.settings.SortMode(m.listDetail))); err != nil {
+				m = m.notifyErr("Failed to save sort mode", err)
+			}
+			m.pager = m.pager.First()
+			return m.reloadLibrary()
+
+		case keybinds["Export"]:
+			if m.exportStatus == "Exporting..." {
+				return m, nil
+			}
+			m.exportStatus = "Exporting..."
+			return m, exportCmd(m.ctx, m.token, m.favoriteStore)
+
+		case keybinds["Stats"]:
+			m.showStats = !m.showStats
+			if m.showStats {
+				return m, m.history.statsCmd(statsPeriods[m.statsPeriod])
+			}
 			return m, nil
 
-		case keybinds["Favorites"]:
-			file := fmt.Sprintf("favorites/%ss.json", m.listDetail)
-			if m.favorites != nil {
-				for _, fav := range m.favorites {
-					if fav.URI == m.libraryList[m.cursor].uri {
-						removeFromJSON(file, fav)
-						m.favorites, _ = readJSON(file)
-						return m, handleFetchLibrary(m.favorites, m.token, m.listDetail, m.height-LIBRARY_SPACING-len(m.favorites), m.offset)
-					}
-				}
-				writeJSONFile(file, LibraryFavorite{m.libraryList[m.cursor].name, m.libraryList[m.cursor].artist, m.libraryList[m.cursor].uri})
+		case keybinds["Messages"]:
+			m.showMessages, m.messageOffset = !m.showMessages, 0
+			return m, nil
+
+		case keybinds["Lyrics"]:
+			m.showLyrics = !m.showLyrics
+			if m.showLyrics && m.lyrics.URI != m.state.Item.URI {
+				return m, m.lyricsFinder.findCmd(m.ctx, newLyricsQuery(m.state))
 			}
-			m.favorites, _ = readJSON(file)
-			return m, handleFetchLibrary(m.favorites, m.token, m.listDetail, m.height-LIBRARY_SPACING-len(m.favorites), m.offset)
+			return m, nil
 
 		case keybinds["Cursor Up"]:
+			if m.showMessages {
+				return m.scrollMessages(-1), nil
+			}
 			if m.cursor > 0 {
 				m.cursor--
 			} else {
@@ -93,6 +271,9 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 
 		case keybinds["Cursor Down"]:
+			if m.showMessages {
+				return m.scrollMessages(1), nil
+			}
 			if m.cursor < len(m.libraryList)-1 {
 				m.cursor++
 			} else {
@@ -100,112 +281,371 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 
 		case keybinds["Next Page"]:
-			m.loading = true
-			if m.offset+m.height-LIBRARY_SPACING-len(m.favorites) < m.apiTotal {
-				if m.offset == 0 {
-					m.offset += m.height - (UI_LIBRARY_SPACE + len(m.favorites))
-				} else {
-					m.offset += m.height - (UI_LIBRARY_SPACE + len(m.favorites)) - 3
-				}
-			} else {
-				m.offset = 0
+			if m.showStats {
+				m.statsPeriod = (m.statsPeriod + 1) % len(statsPeriods)
+				return m, m.history.statsCmd(statsPeriods[m.statsPeriod])
 			}
-			return m, handleFetchLibrary(m.favorites, m.token, m.listDetail, m.height-LIBRARY_SPACING-len(m.favorites), m.offset)
+			return m.turnPage(Paginator.Next)
 
 		case keybinds["Previous Page"]:
-			m.loading = true
-			page := m.offset/(m.height-(UI_LIBRARY_SPACE+len(m.favorites))) + 1
-			if page > 1 {
-				if m.offset == m.height-(UI_LIBRARY_SPACE+len(m.favorites)) {
-					m.offset -= m.height - (UI_LIBRARY_SPACE + len(m.favorites))
-				} else {
-					m.offset -= m.height - (UI_LIBRARY_SPACE + len(m.favorites)) - 3
-				}
-			} else {
-				m.offset = m.apiTotal - (m.apiTotal % (m.height - (UI_LIBRARY_SPACE + len(m.favorites))))
+			if m.showStats {
+				m.statsPeriod = (m.statsPeriod + len(statsPeriods) - 1) % len(statsPeriods)
+				return m, m.history.statsCmd(statsPeriods[m.statsPeriod])
 			}
-			return m, handleFetchLibrary(m.favorites, m.token, m.listDetail, m.height-LIBRARY_SPACING-len(m.favorites), m.offset)
+			return m.turnPage(Paginator.Prev)
+
+		case keybinds["First Page"]:
+			return m.turnPage(Paginator.First)
+
+		case keybinds["Last Page"]:
+			return m.turnPage(Paginator.Last)
+
+		case keybinds["Jump To Page"]:
+			m.prompt = libraryPrompt{kind: PROMPT_PAGE}
+			return m, nil
 
 		case keybinds["Select"]:
-			if m.state.IsPlaying {
-				if m.libraryList != nil {
-					if m.listDetail == "album" {
-						handleGenericPut("/me/player/shuffle", m.token, map[string]string{"state": "false"}, nil)
-					} else {
-						handleGenericPut("/me/player/shuffle", m.token, map[string]string{"state": "true"}, nil)
-					}
-					handleGenericPut("/me/player/play", m.token, map[string]string{"device_id": m.state.Device.ID}, map[string]string{"context_uri": m.libraryList[m.cursor].uri})
-					return m, nil
+			return m.selectRow()
+
+		case keybinds["Assign Slot"]:
+			if len(m.libraryList) == 0 || !m.libraryList[m.cursor].favorite {
+				return m, nil
+			}
+			slot := ""
+			if m.libraryList[m.cursor].slot > 0 {
+				slot = strconv.Itoa(m.libraryList[m.cursor].slot)
+			}
+			return m.openPrompt(PROMPT_SLOT, slot), nil
+
+		default:
+			// Number keys play the favorite bound to that slot, wherever it is in the library
+			if slot, err := strconv.Atoi(msg.String()); err == nil && slot >= 1 && slot <= MAX_SLOT {
+				favorite, ok := m.favoriteStore.BySlot(slot)
+				if ok && m.state.IsPlaying {
+					m.scheduler = m.scheduler.poke(time.Now())
+					return m.startPlay("play "+favorite.Title, func(ctx context.Context, token, deviceID string) error {
+						return handlePlayURI(ctx, token, deviceID, favorite.URI)
+					})
 				}
+				return m, nil
 			}
 		}
 
 	case PlaybackState:
-		if len(msg.Item.Album.Images) > 0 {
-			if m.state.Item.Name != msg.Item.Name {
-				m.image = makeNewImage(msg.Item.Album.Images[0].URL)
-				m.state = msg
-				return m, tea.Batch(scheduleNextFetch(FETCH_TIMER*time.Second), CheckTokenExpiryCmd(m), handleGetQueue(m.token))
-			}
-		}
-		m.state = msg
-		if math.Abs(float64(m.progressMs-msg.ProgressMs)) > 1000 { // Don't bother unless we are more then a second off
-			m.progressMs = msg.ProgressMs
+		return m.playbackPolled(msg, "")
+
+	case playbackIdleMsg:
+		return m.playbackPolled(PlaybackState{}, STATUS_IDLE)
+
+	case playbackFailedMsg:
+		m.scheduler = m.scheduler.finished(time.Now(), m.clock, true)
+		m.status = pollStatus(msg.err)
+		return m.checkToken() // An expired token is the usual reason
+
+	case playerCmdMsg:
+		return m.playerDone(msg)
+
+	case coverMsg:
+		if msg.url == m.state.display().Image { // Still the playing item's cover
+			m.image = msg.image
 		}
-		return m, tea.Batch(scheduleNextFetch(FETCH_TIMER*time.Second), CheckTokenExpiryCmd(m))
+		return m, nil
 
 	case SpotifyTokenResponse:
+		m.refreshingToken = false
 		m.token = msg.AccessToken
 		m.tokenExpiresAt = time.Now().Add(time.Duration(msg.ExpiresIn) * time.Second)
 		return m, nil
 
 	case SpotifyAlbum:
+		if m.listDetail != "album" || m.search != "" || m.artist != nil || m.show != nil { // Arrived after switching libraries or starting a search
+			return m, nil
+		}
+		if m.tagFilter != "" {
+			return m.showTaggedPage(), nil
+		}
 		m.libraryList = nil
-		for _, album := range m.favorites {
-			m.libraryList = append(m.libraryList, LibraryItem{name: album.Title, artist: album.Author, uri: album.URI, favorite: true})
+		for _, album := range m.libraryFavorites() {
+			m.libraryList = append(m.libraryList, LibraryItem{name: album.Title, artist: album.Author, uri: album.URI, favorite: true, group: album.Group, slot: album.Slot})
 		}
 		for _, album := range msg.Items {
-			m.libraryList = append(m.libraryList, LibraryItem{name: album.Album.Name, artist: album.Album.Artists[0].Name, uri: album.Album.URI, favorite: false})
-			m.apiTotal = msg.Total - len(m.favorites)
-			m.loading = false
+			m.libraryList = append(m.libraryList, displayAlbum(album.SpotifyAlbumItem).libraryItem())
 		}
+		m.pager = m.pager.WithTotal(msg.Total)
+		m.loading = false
+		m.cursor = max(0, min(m.cursor, len(m.libraryList)-1))
 
 	case SpotifyPlaylist:
+		if m.listDetail != "playlist" || m.search != "" || m.artist != nil || m.show != nil {
+			return m, nil
+		}
+		if m.tagFilter != "" {
+			return m.showTaggedPage(), nil
+		}
 		m.libraryList = nil
-		for _, playlist := range m.favorites {
-			m.libraryList = append(m.libraryList, LibraryItem{name: playlist.Title, artist: playlist.Author, uri: playlist.URI, favorite: true})
+		for _, playlist := range m.libraryFavorites() {
+			m.libraryList = append(m.libraryList, LibraryItem{name: playlist.Title, artist: playlist.Author, uri: playlist.URI, favorite: true, group: playlist.Group, slot: playlist.Slot})
 		}
 		for _, playlist := range msg.Items {
-			m.libraryList = append(m.libraryList, LibraryItem{name: playlist.Name, artist: playlist.Owner.DisplayName, uri: playlist.URI, favorite: false})
-			m.apiTotal = msg.Total
+			m.libraryList = append(m.libraryList, displayPlaylist(playlist).libraryItem())
+		}
+		m.pager = m.pager.WithTotal(msg.Total)
+		m.loading = false
+		m.cursor = max(0, min(m.cursor, len(m.libraryList)-1))
+
+	case SpotifyShows:
+		if m.listDetail != "show" || m.search != "" || m.artist != nil || m.show != nil {
+			return m, nil
+		}
+		if m.tagFilter != "" {
+			return m.showTaggedPage(), nil
+		}
+		m.libraryList = nil
+		for _, show := range m.libraryFavorites() {
+			m.libraryList = append(m.libraryList, favoriteItem(show, false))
+		}
+		for _, show := range msg.Items {
+			m.libraryList = append(m.libraryList, displayShow(show.Show).libraryItem())
+		}
+		m.pager = m.pager.WithTotal(msg.Total)
+		m.loading = false
+		m.cursor = max(0, min(m.cursor, len(m.libraryList)-1))
+
+	case exportDoneMsg:
+		if msg.err != nil {
+			m.exportStatus = "Export failed"
+			return m.notifyErr("Export failed", msg.err), nil
+		}
+		m.exportStatus = "Exported to " + msg.path
+		return m.notify(NOTICE_INFO, m.exportStatus), nil
+
+	case librarySyncedMsg:
+		if msg.err != nil && !m.offline { // Only when going offline, not on every retry
+			m = m.notify(NOTICE_ERROR, "Library sync failed, showing the saved copy")
+		}
+		m.offline = msg.err != nil
+		if msg.changed && m.listDetail != "favorites" && m.artist == nil && m.show == nil && !m.loading { // Don't cancel a page on its way
+			var fetchCmd tea.Cmd
+			m, fetchCmd = m.reloadLibrary()
+			return m, tea.Batch(syncTickCmd(m.offline), fetchCmd)
+		}
+		return m, syncTickCmd(m.offline)
+
+	case librarySyncTickMsg:
+		return m, m.libraryCache.syncCmd(m.ctx, m.token)
+
+	case librarySearchMsg:
+		if msg.listDetail != m.listDetail || msg.search != m.search || m.artist != nil || m.show != nil { // Results for an older search
+			return m, nil
+		}
+		m.libraryList = append([]LibraryItem{}, msg.items...)
+		m.pager = m.pager.WithTotal(msg.total)
+		m.loading = false
+		m.cursor = max(0, min(m.cursor, len(m.libraryList)-1))
+		return m, nil
+
+	case showEpisodesMsg:
+		if m.show == nil || m.show.Show.uri != msg.showURI {
+			return m, nil
+		}
+		if msg.err != nil {
 			m.loading = false
+			return m.notifyErr("Failed to load episodes", msg.err), nil
 		}
+		page := *m.show
+		page.Episodes = msg.episodes
+		page.pager = page.pager.WithTotal(msg.total)
+		m.show = &page
+		m.libraryList = page.rows(m.favoriteStore)
+		m.loading = false
+		m.cursor = max(0, min(m.cursor, len(m.libraryList)-1))
+		return m, nil
 
-	case Queue:
-		const QUEUE_LENGTH = 5
-		if len(msg.Queue) > QUEUE_LENGTH {
-			msg.Queue = msg.Queue[:QUEUE_LENGTH]
+	case artistLoadedMsg:
+		m.loading = false
+		if msg.err != nil {
+			return m.notifyErr("Failed to open artist", msg.err), nil
+		}
+		return m.showArtist(msg.page)
+
+	case artistAlbumsMsg:
+		if m.artist == nil || m.artist.album != nil || m.artist.Artist.ID != msg.artistID {
+			return m, nil
+		}
+		if msg.err != nil {
+			return m.notifyErr("Failed to load discography", msg.err), nil
+		}
+		page := *m.artist
+		page.Albums = msg.albums
+		page.pager = page.pager.WithTotal(msg.total)
+		m.artist = &page
+		m.libraryList = page.rows(m.favoriteStore)
+		m.cursor = max(0, min(m.cursor, len(m.libraryList)-1))
+		return m, nil
+
+	case albumTracksMsg:
+		m.loading = false
+		if m.artist == nil || m.artist.album == nil || m.artist.album.URI != msg.albumURI {
+			return m, nil
+		}
+		if msg.err != nil {
+			return m.notifyErr("Failed to load album", msg.err), nil
+		}
+		page := *m.artist
+		page.tracks = msg.tracks
+		m.artist = &page
+		m.libraryList = page.rows(m.favoriteStore)
+		return m, nil
+
+	case artistFollowMsg:
+		if msg.err == nil {
+			return m.notify(NOTICE_INFO, map[bool]string{true: "Following", false: "Unfollowed"}[msg.following]+" "+msg.name), nil
+		}
+		m = m.notifyErr("Failed to change following", msg.err)
+		if m.artist != nil && m.artist.Artist.ID == msg.artistID {
+			page := *m.artist
+			page.Following = !msg.following
+			m.artist = &page
+		}
+		return m, nil
+
+	case libraryFetchedMsg:
+		if msg.fetch != m.libraryFetch { // Answers a fetch since replaced by another
+			return m, nil
+		}
+		return m.Update(msg.msg)
+
+	case favoritesPageMsg:
+		if m.listDetail == "favorites" && m.search == "" && m.artist == nil && m.show == nil {
+			return m.showFavoritesPage(), nil
+		}
+		return m, nil
+
+	case Lyrics:
+		if msg.URI == m.state.Item.URI { // Ignore lyrics that arrive after the track changed
+			m.lyrics = msg
 		}
+		return m, nil
+
+	case ListenStats:
+		m.stats = msg
+		return m, nil
+
+	case Queue:
 		m.queue = msg
 		return m, nil
 
+	case notifyTrackMsg:
+		if m.state.Item.URI == msg.uri { // Still on this track, so it wasn't skipped straight past
+			return m, m.notifier.trackCmd(m.ctx, m.state)
+		}
+		return m, nil
+
+	case noticeMsg:
+		return m.notify(msg.level, msg.text), nil
+
+	case tokenFailedMsg:
+		m.refreshingToken = false
+		m.status = STATUS_LOGIN_EXPIRED
+		return m.notifyErr("Failed to refresh login", msg.err), m.notifier.errorCmd(msg.err)
+
 	case error:
-		m.errMsg = msg.Error()
 		m.loading = false
-		return m, scheduleNextFetch(FETCH_TIMER * time.Second)
+		return m.notifyErr("Error", msg), m.notifier.errorCmd(msg)
+
+	case tickMsg:
+		return m.tick()
+	}
+	return m, nil
+}
 
-	case playbackMsg:
-		return m, handleFetchPlayback(m.token)
+// playbackPolled takes in the result of a playback poll: the state, or an empty one if nothing is playing anywhere.
+//
+// Parameters:
+// - state: The polled playback state.
+// - status: The status line condition the poll leaves behind, "" if all is well.
+//
+// Returns:
+// - The updated model, and commands for listens, the queue and the new item's cover and lyrics.
+func (m Model) playbackPolled(state PlaybackState, status string) (Model, tea.Cmd) {
+	if m.pendingCommands > 0 { // May have been sampled before the command landed, the poll after it will tell
+		m.scheduler = m.scheduler.finished(time.Now(), m.clock, false)
+		return m, nil
+	}
+	m.clock = m.clock.sync(state, state.sampledAt)
+	m.scheduler = m.scheduler.finished(time.Now(), m.clock, false)
+	m.status = status
+	started, finished := m.listens.observe(state, time.Now())
+	listenCmds := tea.Batch(m.scrobbler.observeCmd(m.ctx, started, finished), m.history.recordCmd(finished), m.nowPlaying.exportCmd(m.ctx, state), m.notifier.changeCmd(m.polled, state))
+	changed := m.state.Item.URI != state.Item.URI
+	m.state, m.polled = state, state
+	m, tokenCmd := m.checkToken()
+	if !changed {
+		return m, tea.Batch(tokenCmd, listenCmds)
+	}
+	m, itemCmd := m.showItem()
+	if state.Item.URI == "" { // Nothing to queue after
+		m.queue = Queue{}
+		return m, tea.Batch(tokenCmd, listenCmds, itemCmd)
+	}
+	return m, tea.Batch(tokenCmd, handleGetQueue(m.ctx, m.token), listenCmds, itemCmd)
+}
 
-	case progressMsg:
-		if m.state.IsPlaying {
-			m.progressMs += 1000
-			if m.state.Item.DurationMs-m.progressMs < 2000 {
-				return m, tea.Batch(scheduleProgressInc(1 * time.Second))
-			}
+// libraryFetchedMsg is the answer to a library fetch, shown only if no newer fetch has started since.
+type libraryFetchedMsg struct {
+	fetch int
+	msg   tea.Msg
+}
+
+// libraryCmd wraps the command of library fetch number fetch, dropping its answer if the fetch was cancelled.
+func libraryCmd(ctx context.Context, fetch int, cmd tea.Cmd) tea.Cmd {
+	if cmd == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		msg := cmd()
+		if ctx.Err() != nil { // Replaced by a newer fetch, or quitting
+			return nil
 		}
+		return libraryFetchedMsg{fetch: fetch, msg: msg}
+	}
+}
+
+// fetchLibrary starts a fetch for the library pane, cancelling the one still in flight.
+// Paging quickly would otherwise let a slow answer for an old page replace a newer one.
+//
+// Parameters:
+// - fetch: Builds the fetch's command, given a context cancelled when the fetch is replaced.
+//
+// Returns:
+// - The updated model, and the fetch's command wrapped by libraryCmd.
+func (m Model) fetchLibrary(fetch func(ctx context.Context) tea.Cmd) (Model, tea.Cmd) {
+	if m.libraryCancel != nil {
+		m.libraryCancel()
+	}
+	var ctx context.Context
+	ctx, m.libraryCancel = context.WithCancel(m.ctx)
+	m.libraryFetch++
+	return m, libraryCmd(ctx, m.libraryFetch, fetch(ctx))
+}
 
-		return m, scheduleProgressInc(1 * time.Second)
+// selectRow acts on the library row under the cursor: drill into an artist page row or a show, play an episode, or play the row.
+func (m Model) selectRow() (Model, tea.Cmd) {
+	m.scheduler = m.scheduler.poke(time.Now()) // Most rows start playing something
+	if m.artist != nil {
+		return m.selectArtistRow()
+	}
+	if m.show != nil {
+		return m.selectEpisode()
+	}
+	if len(m.libraryList) > 0 && uriKind(m.libraryList[m.cursor].uri) == "show" {
+		return m.openShow(m.libraryList[m.cursor])
+	}
+	if m.state.IsPlaying && len(m.libraryList) > 0 {
+		uri := m.libraryList[m.cursor].uri
+		return m.startPlay("play", func(ctx context.Context, token, deviceID string) error {
+			return handlePlayURI(ctx, token, deviceID, uri)
+		})
 	}
 	return m, nil
 }
@@ -215,19 +655,23 @@ func (m Model) View() string {
 	if err != nil {
 		log.Fatalf("Failed to get terminal size: %v", err)
 	}
-	boxWidth := width/2 - 2
-	boxHeight := height - UI_LIBRARY_SPACE
-	playBackWidth := width - 2
+	layout := newScreenLayout(width, height)
+	boxWidth, boxHeight, playBackWidth := layout.boxWidth, layout.boxHeight, layout.playBackWidth
 
-	libText, playback, image, visQueue := getUiElements(m, boxWidth)
+	libText, playback, image, visQueue := getUiElements(m, boxWidth, playBackWidth)
 
-	visQueueHeight := 8
-	jukeboxHeight := boxHeight - visQueueHeight - 2
+	if m.showStats {
+		stats := libraryStyle.Width(playBackWidth).Height(boxHeight).Render(getStatsText(m, playBackWidth))
+		return lipgloss.JoinVertical(lipgloss.Left, stats, playBackStyle.Width(playBackWidth).Height(3).Render(playback))
+	}
 
 	library := libraryStyle.Width(boxWidth).Height(boxHeight).Render(libText)
-	jukebox := boxStyle.Width(boxWidth).Height(jukeboxHeight).Render(image)
-	playbackBar := boxStyle.Width(playBackWidth).Height(1).Render(playback)
-	visualQueue := boxStyle.Width(boxWidth).Height(visQueueHeight).Render(visQueue)
+	jukebox := boxStyle.Width(boxWidth).Height(layout.jukeboxHeight).Render(image)
+	playbackBar := playBackStyle.Width(playBackWidth).Height(3).Render(playback)
+	if m.showLyrics {
+		visQueue = getLyricsText(m, boxWidth, QUEUE_HEIGHT)
+	}
+	visualQueue := boxStyle.Width(boxWidth).Height(QUEUE_HEIGHT).Render(visQueue)
 
 	return lipgloss.JoinVertical(
 		lipgloss.Left,
@@ -237,41 +681,59 @@ func (m Model) View() string {
 }
 
 func main() {
-	
 
-	err := godotenv.Load(".env")
-	if err != nil {
-		log.Fatalf("Error loading .env file: %v", err)
-	}
+	loadEnv()
 
 	clientID := os.Getenv("SPOTIFY_ID")
 	clientSecret := os.Getenv("SPOTIFY_SECRET")
 	listDetail := os.Getenv("SPOTIFY_PREFERENCE")
 
+	if len(os.Args) > 1 && os.Args[1] == "status" {
+		runStatus(os.Args[2:])
+		return
+	}
+
 	setKeybinds()
 
 	checkArguments()
 
-	fmt.Println("Opening login page...")
-	OpenLoginPage(clientID)
-	code := GetCodeFromCallback()
-	token, err := GetSpotifyToken(context.Background(), clientID, clientSecret, code)
-	if err != nil {
-		log.Fatalf("Failed to get token: %v", err)
+	if len(os.Args) > 1 && (os.Args[1] == "export" || os.Args[1] == "import") {
+		token := login(clientID, clientSecret)
+		favoriteStore := loadFavorites()
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt) // Ctrl+C cancels the requests in flight
+		defer stop()
+		if os.Args[1] == "export" {
+			runExport(ctx, token.AccessToken, favoriteStore, os.Args[2:])
+		} else {
+			runImport(ctx, token.AccessToken, favoriteStore, os.Args[2:])
+		}
+		return
 	}
+
+	token := login(clientID, clientSecret)
 	fmt.Println("Login successful! Access token retrieved.\n" + fmt.Sprintf("Press '%s' to Play/Pause, '%s' to Skip, '%s' to Quit", keybinds["Play/Pause"], keybinds["Skip"], keybinds["Quit"]))
 
-	favorites, success := readJSON(fmt.Sprintf("favorites/%ss.json", listDetail))
-	if !success {
-		fmt.Println("No favorites found. Creating new favorites file.")
-		createEmptyJSONFile(fmt.Sprintf("favorites/%ss.json", listDetail))
+	favoriteStore := loadFavorites()
+
+	history, err := openHistory(dataPath(HISTORY_DB))
+	if err != nil {
+		fmt.Println("Listening history unavailable: ", err)
 	}
+	defer history.Close()
 
-	model := initialModel(token.AccessToken, listDetail, favorites)
+	nowPlaying := newNowPlayingExporter()
+	defer nowPlaying.clear()
+
+	model := initialModel(token.AccessToken, listDetail, favoriteStore, openLibraryCache(dataPath(LIBRARY_CACHE)), history, nowPlaying)
 	model.refreshToken = token.RefreshToken
+	defer model.notifier.Close()
 	model.tokenExpiresAt = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
 
-	p := tea.NewProgram(model)
+	var options []tea.ProgramOption
+	if queryEnv("MOUSE", "on") != "off" {
+		options = append(options, tea.WithMouseCellMotion())
+	}
+	p := tea.NewProgram(model, options...)
 	if _, err := p.Run(); err != nil {
 		log.Fatalf("Error: %v", err)
 	}
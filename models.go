@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"time"
 )
 
@@ -9,6 +10,14 @@ import (
 // =====================================
 
 type Model struct {
+	// Cancelled on quit, abandoning every request still in flight
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// Cancels the library fetch in flight, and numbers fetches so only the latest one's answer is shown
+	libraryCancel context.CancelFunc
+	libraryFetch  int
+
 	//Playback state, including track info, playback status, etc.
 	state PlaybackState
 
@@ -21,13 +30,26 @@ type Model struct {
 	//Time when the current access token expires.
 	tokenExpiresAt time.Time
 
-	//Error message, if any
-	errMsg string
+	// Every notice shown this session, oldest first, for the messages view
+	notices []Notice
+
+	// The latest notice, shown as a toast in the status line for a few seconds
+	toast Notice
+
+	// Lasting problem shown in the status line, e.g. STATUS_NETWORK_DOWN, or ""
+	status string
+
+	// Whether an access token refresh is in flight
+	refreshingToken bool
+
+	// Whether the messages view is shown in place of the library, and how far it is scrolled
+	showMessages  bool
+	messageOffset int
 
 	//Whether or not we're currently fetching access token initially
 	loading bool
 
-	//List detail, either "album" or "playlist".
+	//List detail, either "album", "playlist", "show" or "favorites".
 	listDetail string
 
 	//Cursor for the list of albums/playlists.
@@ -39,30 +61,99 @@ type Model struct {
 	//Height of the list of albums/playlists.
 	height int
 
-	//Progress of current track in ms
-	progressMs int
+	// Progress of the playing item, interpolated between polls
+	clock PlaybackClock
+
+	// When to poll playback next, and whether a poll is in flight
+	scheduler Scheduler
+
+	// Last playback state from the API. state may be ahead of it while a playback command is pending
+	polled PlaybackState
+
+	// Playback commands sent but not yet answered. Polls are ignored meanwhile, they may predate the command
+	pendingCommands int
 
 	// Album cover image as string
 	image string
 
-	// Offset for pagination of albums/playlists
-	offset int
-
-	// Total Library Items
-	apiTotal int
+	// Pagination of the library
+	pager Paginator
 
 	// Favorites list
 	favorites []LibraryFavorite
 
+	// Persisted favorites, the source of the favorites list
+	favoriteStore *FavoritesStore
+
+	// Only show favorites with this tag, if set
+	tagFilter string
+
+	// Artist page shown in place of the library, if any
+	artist *ArtistPage
+
+	// Episode list of a show, shown in place of the library, if any
+	show *ShowPage
+
+	// Fuzzy search over the whole library, if set
+	search string
+
+	// Text prompt currently open in the library, if any
+	prompt libraryPrompt
+
 	// Queue list
 	queue Queue //This isnt what itll be
-}
 
-// playbackMsg tells the update to fetch playback state.
-type playbackMsg struct{}
+	// Turns playback polls into discrete listens
+	listens *listenTracker
+
+	// Scrobbler for Last.fm/ListenBrainz, nil if not configured
+	scrobbler *Scrobbler
+
+	// Local listening history, nil if the database couldn't be opened
+	history *History
+
+	// Whether the stats screen is shown instead of the library
+	showStats bool
+
+	// Index into statsPeriods for the stats screen
+	statsPeriod int
 
-// progressMsg tells the update to update the progress of the current track.
-type progressMsg struct{}
+	// Latest stats computed from the listening history
+	stats ListenStats
+
+	// Writes the current track to status bar/OBS sinks
+	nowPlaying *NowPlayingExporter
+
+	// Desktop notifications, nil if disabled
+	notifier *Notifier
+
+	// Looks up lyrics for the playing track
+	lyricsFinder *LyricsFinder
+
+	// Whether the lyrics pane is shown in place of the queue
+	showLyrics bool
+
+	// Lyrics for the playing track, once found
+	lyrics Lyrics
+
+	// Progress or result of the last in-app export
+	exportStatus string
+
+	// Local copy of the saved library, synced in the background
+	libraryCache *LibraryCache
+
+	// Preferences changed inside the app, like each library's sort mode
+	settings *Settings
+
+	// Whether the last library sync failed, so pages are served from a stale cache
+	offline bool
+
+	// How far a title too long for the playback bar has scrolled, one step per progress tick
+	marquee int
+
+	// First queue entry shown, moved by the scroll wheel
+	queueOffset int
+}
 
 // SpotifyTokenResponse struct for parsing the access token response.
 type SpotifyTokenResponse struct {
@@ -75,48 +166,67 @@ type SpotifyTokenResponse struct {
 // PlaybackState struct for parsing the playback state response.
 type PlaybackState struct {
 	Device struct {
-		ID string `json:"id"`
+		ID   string `json:"id"`
+		Name string `json:"name"`
 	} `json:"device"`
 	ShuffleState bool   `json:"shuffle_state"`
 	RepeatState  string `json:"repeat_state"`
 	Context      struct {
 		URI string `json:"uri"`
 	} `json:"context"`
-	ProgressMs int `json:"progress_ms"`
-	Item       struct {
-		Album struct {
-			AlbumType string `json:"album_type"`
-			Artists   []struct {
-				Href string `json:"href"`
-				ID   string `json:"id"`
-				Name string `json:"name"`
-				Type string `json:"type"`
-				URI  string `json:"uri"`
-			} `json:"artists"`
-			Href   string `json:"href"`
-			ID     string `json:"id"`
-			Images []struct {
-				URL string `json:"url"`
-			} `json:"images"`
-			Name string `json:"name"`
-			Type string `json:"type"`
-			URI  string `json:"uri"`
-		} `json:"album"`
-		Artists []struct {
+	ProgressMs int          `json:"progress_ms"`
+	Item       PlayableItem `json:"item"`
+	IsPlaying  bool         `json:"is_playing"`
+	Timestamp  int64        `json:"timestamp"` // Unix ms of the last change to the state
+
+	sampledAt time.Time // Local time the API is taken to have sampled ProgressMs
+}
+
+// PlayableItem struct for parsing a track or episode, as playing or in the queue.
+type PlayableItem struct {
+	Album struct {
+		AlbumType string `json:"album_type"`
+		Artists   []struct {
 			Href string `json:"href"`
 			ID   string `json:"id"`
 			Name string `json:"name"`
 			Type string `json:"type"`
 			URI  string `json:"uri"`
 		} `json:"artists"`
-		DurationMs int    `json:"duration_ms"`
-		Href       string `json:"href"`
-		ID         string `json:"id"`
-		Name       string `json:"name"`
-		Type       string `json:"type"`
-		URI        string `json:"uri"`
-	} `json:"item"`
-	IsPlaying bool `json:"is_playing"`
+		Href   string `json:"href"`
+		ID     string `json:"id"`
+		Images []struct {
+			URL string `json:"url"`
+		} `json:"images"`
+		Name string `json:"name"`
+		Type string `json:"type"`
+		URI  string `json:"uri"`
+	} `json:"album"`
+	Artists []struct {
+		Href string `json:"href"`
+		ID   string `json:"id"`
+		Name string `json:"name"`
+		Type string `json:"type"`
+		URI  string `json:"uri"`
+	} `json:"artists"`
+	DurationMs int    `json:"duration_ms"`
+	Href       string `json:"href"`
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Type       string `json:"type"` // track or episode
+	URI        string `json:"uri"`
+	IsLocal    bool   `json:"is_local"`
+	Images     []struct {
+		URL string `json:"url"`
+	} `json:"images"` // Episode artwork; tracks use their album's
+	Show struct {
+		Name      string `json:"name"`
+		Publisher string `json:"publisher"`
+		URI       string `json:"uri"`
+		Images    []struct {
+			URL string `json:"url"`
+		} `json:"images"`
+	} `json:"show"` // Only set for episodes
 }
 
 // SpotifyAlbum struct for parsing the albums response.
@@ -129,10 +239,14 @@ type SpotifyAlbum struct {
 
 // SpotifyAlbumItem struct for parsing the album item response.
 type SpotifyAlbumItem struct {
-	Album struct {
-		Name    string `json:"name"`
-		URI     string `json:"uri"`
-		Artists []struct {
+	AddedAt string `json:"added_at"`
+	Album   struct {
+		ID          string `json:"id"`
+		Name        string `json:"name"`
+		URI         string `json:"uri"`
+		ReleaseDate string `json:"release_date"`
+		TotalTracks int    `json:"total_tracks"`
+		Artists     []struct {
 			Name string `json:"name"`
 		}
 	}
@@ -145,11 +259,104 @@ type SpotifyPlaylist struct {
 }
 
 type SpotifyPlaylistItem struct {
-	Name  string `json:"name"`
-	URI   string `json:"uri"`
-	Owner struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	URI        string `json:"uri"`
+	SnapshotID string `json:"snapshot_id"`
+	Owner      struct {
 		DisplayName string `json:"display_name"`
 	}
+	Tracks struct {
+		Total int `json:"total"`
+	} `json:"tracks"`
+}
+
+// SpotifyPage struct for parsing any paginated list response.
+type SpotifyPage[T any] struct {
+	Items []T `json:"items"`
+	Total int `json:"total"`
+}
+
+// SpotifyTrack struct for parsing a track inside playlist and saved track responses.
+type SpotifyTrack struct {
+	Name       string `json:"name"`
+	URI        string `json:"uri"`
+	DurationMs int    `json:"duration_ms"`
+	IsLocal    bool   `json:"is_local"`
+	Artists    []struct {
+		Name string `json:"name"`
+		URI  string `json:"uri"`
+	} `json:"artists"`
+	Album struct {
+		Name string `json:"name"`
+	} `json:"album"`
+}
+
+// SpotifySavedTrack struct for parsing playlist track and Liked Songs items. Track is nil for removed tracks.
+type SpotifySavedTrack struct {
+	AddedAt string        `json:"added_at"`
+	Track   *SpotifyTrack `json:"track"`
+}
+
+// SpotifyShowItem struct for parsing a saved show.
+type SpotifyShowItem struct {
+	AddedAt string      `json:"added_at"`
+	Show    SpotifyShow `json:"show"`
+}
+
+// SpotifyShow struct for parsing a podcast show.
+type SpotifyShow struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	URI           string `json:"uri"`
+	Publisher     string `json:"publisher"`
+	TotalEpisodes int    `json:"total_episodes"`
+}
+
+// SpotifyShows struct for parsing a page of saved shows.
+type SpotifyShows struct {
+	Items []SpotifyShowItem `json:"items"`
+	Total int               `json:"total"`
+}
+
+// SpotifyEpisode struct for parsing an episode of a show, with where the user left off.
+type SpotifyEpisode struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	URI         string `json:"uri"`
+	DurationMs  int    `json:"duration_ms"`
+	ReleaseDate string `json:"release_date"`
+	ResumePoint struct {
+		FullyPlayed      bool `json:"fully_played"`
+		ResumePositionMs int  `json:"resume_position_ms"`
+	} `json:"resume_point"`
+}
+
+// SpotifyArtist struct for parsing an artist.
+type SpotifyArtist struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	URI       string   `json:"uri"`
+	Genres    []string `json:"genres"`
+	Followers struct {
+		Total int `json:"total"`
+	} `json:"followers"`
+}
+
+// SpotifyArtistAlbum struct for parsing an album in an artist's discography.
+type SpotifyArtistAlbum struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	URI         string `json:"uri"`
+	AlbumGroup  string `json:"album_group"` // album, single, compilation or appears_on
+	ReleaseDate string `json:"release_date"`
+	TotalTracks int    `json:"total_tracks"`
+}
+
+// SpotifyUser struct for parsing the current user's profile.
+type SpotifyUser struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"display_name"`
 }
 
 // LibraryItem struct for storing album/playlist information.
@@ -158,29 +365,35 @@ type LibraryItem struct {
 	artist   string
 	uri      string
 	favorite bool
+	group    string
+	slot     int
+
+	// Characters (grapheme clusters) of name and artist matched by the library search, for highlighting
+	nameMatches   []int
+	artistMatches []int
 }
 
-// LibraryFavorite struct for storing favorite album/playlist information.
+// LibraryFavorite struct for storing a favorite of any kind: album, playlist, artist, track or show.
 type LibraryFavorite struct {
-	Title  string `json:"title"`
-	Author string `json:"author"`
-	URI    string `json:"URI"`
+	Title  string   `json:"title"`
+	Author string   `json:"author"`
+	URI    string   `json:"URI"`
+	Group  string   `json:"group,omitempty"`
+	Tags   []string `json:"tags,omitempty"`
+	Slot   int      `json:"slot,omitempty"` // Number key (1-9) that plays this favorite, 0 if none
 }
 
-// Queue struct for storing the queue of songs.
-type Queue struct {
-	Queue []QueueItem `json:"queue"`
+// HasTag reports whether the favorite is tagged with a tag.
+func (f LibraryFavorite) HasTag(tag string) bool {
+	for _, t := range f.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
 }
 
-// QueueItem struct for storing the queue item information.
-type QueueItem struct {
-	Href    string `json:"href"`
-	ID      string `json:"id"`
-	Name    string `json:"name"`
-	Type    string `json:"type"`
-	URI     string `json:"uri"`
-	IsLocal bool   `json:"is_local"`
-	Artists []struct {
-		Name string `json:"name"`
-	} `json:"artists"`
+// Queue struct for storing the queue of songs.
+type Queue struct {
+	Queue []PlayableItem `json:"queue"`
 }
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"os"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/term"
+)
+
+// ==================================================
+// ===== mouse.go | Clicks and the scroll wheel =====
+// ==================================================
+
+// updateMouse handles a mouse event: clicking the progress bar seeks, clicking a library row moves
+// the cursor there (or plays it, if the cursor is already there), and the wheel scrolls the library and queue.
+func (m Model) updateMouse(msg tea.MouseMsg) (Model, tea.Cmd) {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		return m, nil
+	}
+	layout := newScreenLayout(width, height)
+	inLibrary := msg.X < layout.rightPaneLeft() && msg.Y < layout.playBackTop() && !m.showStats
+	inQueue := msg.X >= layout.rightPaneLeft() && msg.Y >= layout.queueTop() && msg.Y < layout.playBackTop() && !m.showLyrics
+
+	switch msg.Button {
+	case tea.MouseButtonWheelUp, tea.MouseButtonWheelDown:
+		step := 1
+		if msg.Button == tea.MouseButtonWheelUp {
+			step = -1
+		}
+		if inQueue {
+			m.queueOffset = max(0, min(m.queueOffset+step, len(m.queue.Queue)-QUEUE_ROWS))
+			return m, nil
+		}
+		if inLibrary && m.showMessages {
+			return m.scrollMessages(step), nil
+		}
+		if inLibrary {
+			return m.scrollLibrary(step)
+		}
+
+	case tea.MouseButtonLeft:
+		if msg.Action != tea.MouseActionPress {
+			return m, nil
+		}
+		if msg.Y == layout.progressBarRow() {
+			return m.seekTo(msg.X-layout.progressBarLeft(), layout.progressBarWidth())
+		}
+		if inLibrary && !m.showMessages {
+			row, ok := m.libraryRowAt(msg.Y - layout.libraryRowsTop())
+			if !ok {
+				return m, nil
+			}
+			if row == m.cursor {
+				return m.selectRow()
+			}
+			m.cursor = row
+		}
+	}
+	return m, nil
+}
+
+// libraryRowAt finds the library row drawn on a line of the library pane, counting from the first row.
+// Artist pages have section headings between their rows, which aren't rows themselves.
+// Headers and headings are truncated to the pane, so each takes exactly one line.
+func (m Model) libraryRowAt(line int) (int, bool) {
+	if line < 0 {
+		return 0, false
+	}
+	if m.artist == nil {
+		return line, line < len(m.libraryList)
+	}
+	section, current := "", 0
+	for i := range m.libraryList {
+		if next := m.artist.section(i); next != section {
+			section = next
+			if current == line {
+				return 0, false
+			}
+			current++
+		}
+		if current == line {
+			return i, true
+		}
+		current++
+	}
+	return 0, false
+}
+
+// scrollLibrary moves the library cursor a row, turning the page at either end of it.
+// Artist pages only scroll within the page, since only their discography is paged.
+func (m Model) scrollLibrary(step int) (Model, tea.Cmd) {
+	next := m.cursor + step
+	if next >= 0 && next < len(m.libraryList) {
+		m.cursor = next
+		return m, nil
+	}
+	if m.artist != nil || m.loading {
+		return m, nil
+	}
+	pager := m.libraryPager()
+	if m.show != nil {
+		pager = m.show.pager
+	}
+	switch {
+	case next < 0 && pager.Page() > 0:
+		return m.turnPage(Paginator.Prev)
+	case next >= len(m.libraryList) && pager.Page() < pager.Pages()-1:
+		return m.turnPage(Paginator.Next)
+	}
+	return m, nil
+}
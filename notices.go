@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ==============================================================
+// ===== notices.go | Toasts, the status line, and messages =====
+// ==============================================================
+
+const (
+	NOTICE_INFO    = "info"
+	NOTICE_ERROR   = "error"
+	NOTICE_HISTORY = 200 // Notices kept for the messages view
+	TOAST_DURATION = 4 * time.Second
+)
+
+// Conditions shown in the status line for as long as they last.
+const (
+	STATUS_NETWORK_DOWN  = "Network down, retrying"
+	STATUS_RATE_LIMITED  = "Rate limited by Spotify, slowing down"
+	STATUS_IDLE          = "Nothing playing, start playing in a Spotify app"
+	STATUS_MISSING_SCOPE = "Missing permissions, restart JukeTUI to log in again"
+	STATUS_LOGIN_EXPIRED = "Login expired"
+	STATUS_REFRESHING    = "Refreshing login..."
+)
+
+// Notice is a message for the user: how something they did went, or something that went wrong.
+type Notice struct {
+	Level string
+	Text  string
+	At    time.Time
+}
+
+// noticeMsg lets any command report back to the user without a message type of its own.
+type noticeMsg struct {
+	level string
+	text  string
+}
+
+// tokenFailedMsg reports a failed token refresh.
+type tokenFailedMsg struct {
+	err error
+}
+
+// notify records a notice in the history and shows it as a toast.
+func (m Model) notify(level, text string) Model {
+	notice := Notice{Level: level, Text: text, At: time.Now()}
+	keep := m.notices[max(0, len(m.notices)+1-NOTICE_HISTORY):]
+	m.notices = append(append([]Notice{}, keep...), notice) // Copied, older models may still hold the old slice
+	m.toast = notice
+	return m
+}
+
+// notifyErr logs an error and shows it as an error notice, prefixed with what was being done.
+func (m Model) notifyErr(doing string, err error) Model {
+	errorLogger.Printf("%s: %v", doing, err)
+	return m.notify(NOTICE_ERROR, fmt.Sprintf("%s: %v", doing, err))
+}
+
+// pollStatus gets the status line condition a failed playback poll leaves behind.
+//
+// Parameters:
+// - err: Why the poll failed, an *APIError if Spotify answered.
+//
+// Returns:
+// - One of the STATUS_ conditions, or a generic one for other failures.
+func pollStatus(err error) string {
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return STATUS_NETWORK_DOWN
+	}
+	switch apiStatus(err) {
+	case http.StatusTooManyRequests:
+		return STATUS_RATE_LIMITED
+	case http.StatusForbidden:
+		return STATUS_MISSING_SCOPE
+	case http.StatusUnauthorized:
+		return STATUS_LOGIN_EXPIRED
+	}
+	return fmt.Sprintf("Spotify isn't answering (%v), retrying", err)
+}
+
+// checkToken starts refreshing the access token if it has expired and no refresh is running yet.
+func (m Model) checkToken() (Model, tea.Cmd) {
+	if m.refreshingToken {
+		return m, nil
+	}
+	cmd := CheckTokenExpiryCmd(m)
+	m.refreshingToken = cmd != nil
+	return m, cmd
+}
+
+// Generate the status line under the progress bar: lasting conditions on the left, the latest toast on the right
+func getStatusLine(m Model, width int) string {
+	status := m.status
+	if m.refreshingToken {
+		status = STATUS_REFRESHING
+	}
+	left := ""
+	if status != "" {
+		left = lipgloss.NewStyle().Foreground(lipgloss.Color(WARNING_YELLOW)).Render("⚠ " + truncate(status, width/2-2))
+	}
+
+	right := lipgloss.NewStyle().Faint(true).Render(fmt.Sprintf("Messages: %s", keybinds["Messages"]))
+	if m.toast.Text != "" && time.Since(m.toast.At) < TOAST_DURATION {
+		color := SPOTIFY_GREEN
+		if m.toast.Level == NOTICE_ERROR {
+			color = ERROR_RED
+		}
+		right = lipgloss.NewStyle().Foreground(lipgloss.Color(color)).Render(truncate(m.toast.Text, width-textWidth(left)-1))
+	}
+	return left + strings.Repeat(" ", max(1, width-textWidth(left)-textWidth(right))) + right
+}
+
+// Generate the messages view for the library pane: every notice, newest first, scrolled to the messages offset
+func getMessagesText(m Model, boxWidth, rows int) string {
+	text := lipgloss.NewStyle().Bold(true).Render(fmt.Sprintf("Messages (%d)", len(m.notices))) + "\n"
+	if len(m.notices) == 0 {
+		return text + "  Nothing yet"
+	}
+	offset := max(0, min(m.messageOffset, len(m.notices)-rows))
+	for i := len(m.notices) - 1 - offset; i >= 0 && i >= len(m.notices)-offset-rows; i-- {
+		notice := m.notices[i]
+		style := lipgloss.NewStyle()
+		if notice.Level == NOTICE_ERROR {
+			style = style.Foreground(lipgloss.Color(ERROR_RED))
+		}
+		stamp := notice.At.Format("15:04:05") + "  "
+		text += stamp + style.Render(truncate(notice.Text, boxWidth-textWidth(stamp))) + "\n"
+	}
+	return text
+}
+
+// scrollMessages moves the messages view a line, older for positive steps.
+func (m Model) scrollMessages(step int) Model {
+	m.messageOffset = max(0, min(m.messageOffset+step, len(m.notices)-(m.height-LIBRARY_SPACING)))
+	return m
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/godbus/dbus/v5"
+)
+
+// ==========================================================
+// ===== notifier.go | Desktop notifications over D-Bus =====
+// ==========================================================
+
+const (
+	NOTIFY_DEST     = "org.freedesktop.Notifications"
+	NOTIFY_PATH     = "/org/freedesktop/Notifications"
+	NOTIFY_DEBOUNCE = 1500 * time.Millisecond // Wait this long on a new track, so rapid skips only notify once
+	NOTIFY_EXPIRE   = 5000                    // Milliseconds a notification stays up
+)
+
+// Notification events that can be toggled individually.
+const (
+	EVENT_TRACK  = "track"
+	EVENT_PAUSE  = "pause"
+	EVENT_DEVICE = "device"
+	EVENT_ERROR  = "error"
+)
+
+// Notifier sends freedesktop notifications for playback events.
+type Notifier struct {
+	conn   *dbus.Conn
+	events map[string]bool
+	mu     sync.Mutex
+	lastID uint32 // Replaced by the next notification, so they don't pile up
+}
+
+// notifyTrackMsg fires after the debounce delay for a track change.
+type notifyTrackMsg struct {
+	uri string
+}
+
+// newNotifier builds a notifier from the environment. Returns nil if notifications are off or there is no session bus.
+func newNotifier() *Notifier {
+	if os.Getenv("NOTIFICATIONS") != "true" {
+		return nil
+	}
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		errorLogger.Println("Failed to connect to the session bus, notifications disabled: ", err)
+		return nil
+	}
+	return &Notifier{
+		conn: conn,
+		events: map[string]bool{
+			EVENT_TRACK:  queryEnv("NOTIFY_TRACK", "true") == "true",
+			EVENT_PAUSE:  queryEnv("NOTIFY_PAUSE", "false") == "true",
+			EVENT_DEVICE: queryEnv("NOTIFY_DEVICE", "true") == "true",
+			EVENT_ERROR:  queryEnv("NOTIFY_ERRORS", "false") == "true",
+		},
+	}
+}
+
+// Close closes the session bus connection.
+func (n *Notifier) Close() error {
+	if n == nil {
+		return nil
+	}
+	return n.conn.Close()
+}
+
+// enabled reports whether notifications are wanted for an event.
+func (n *Notifier) enabled(event string) bool {
+	return n != nil && n.events[event]
+}
+
+// changeCmd compares two playback states and returns commands for any notifications they call for.
+//
+// Parameters:
+// - prev: The playback state currently shown.
+// - next: The playback state just fetched.
+//
+// Returns:
+// - A command sending the notifications, or nil.
+func (n *Notifier) changeCmd(prev, next PlaybackState) tea.Cmd {
+	if n == nil || prev.Item.URI == "" || next.Item.URI == "" { // Startup or a failed fetch, nothing changed
+		return nil
+	}
+	var cmds []tea.Cmd
+	if n.enabled(EVENT_TRACK) && prev.Item.URI != next.Item.URI {
+		uri := next.Item.URI
+		cmds = append(cmds, tea.Tick(NOTIFY_DEBOUNCE, func(time.Time) tea.Msg {
+			return notifyTrackMsg{uri: uri}
+		}))
+	}
+	if n.enabled(EVENT_PAUSE) && prev.IsPlaying != next.IsPlaying {
+		summary := "Paused"
+		if next.IsPlaying {
+			summary = "Resumed"
+		}
+		cmds = append(cmds, n.notifyCmd(summary, next.Item.Name, ""))
+	}
+	if n.enabled(EVENT_DEVICE) && prev.Device.ID != next.Device.ID && next.Device.ID != "" {
+		cmds = append(cmds, n.notifyCmd("Playing on "+next.Device.Name, next.Item.Name, ""))
+	}
+	return tea.Batch(cmds...)
+}
+
+// trackCmd returns a command announcing the playing track, with its cover as the icon.
+func (n *Notifier) trackCmd(ctx context.Context, state PlaybackState) tea.Cmd {
+	if !n.enabled(EVENT_TRACK) {
+		return nil
+	}
+	display := state.display()
+	body := display.Artist()
+	if display.Subtitle != "" {
+		body += "\n" + display.Subtitle
+	}
+	cover := display.Image
+	return func() tea.Msg {
+		icon := ""
+		if cover != "" {
+			if path, err := cachedCover(ctx, cover); err == nil {
+				icon = "file://" + path
+			}
+		}
+		n.send(display.Title, body, icon)
+		return nil
+	}
+}
+
+// errorCmd returns a command reporting an error, if error notifications are on.
+func (n *Notifier) errorCmd(err error) tea.Cmd {
+	if !n.enabled(EVENT_ERROR) {
+		return nil
+	}
+	return n.notifyCmd("JukeTUI error", err.Error(), "dialog-error")
+}
+
+// notifyCmd wraps send in a command.
+func (n *Notifier) notifyCmd(summary, body, icon string) tea.Cmd {
+	return func() tea.Msg {
+		n.send(summary, body, icon)
+		return nil
+	}
+}
+
+// send calls Notify on the notification daemon, replacing our previous notification.
+func (n *Notifier) send(summary, body, icon string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	obj := n.conn.Object(NOTIFY_DEST, NOTIFY_PATH)
+	call := obj.Call(NOTIFY_DEST+".Notify", 0,
+		"JukeTUI", n.lastID, icon, summary, body,
+		[]string{}, map[string]dbus.Variant{}, int32(NOTIFY_EXPIRE),
+	)
+	if call.Err != nil {
+		errorLogger.Println("Failed to send notification: ", call.Err)
+		return
+	}
+	if err := call.Store(&n.lastID); err != nil {
+		errorLogger.Println("Failed to read notification id: ", err)
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ======================================================================
+// ===== nowPlaying.go | Export the current track to other programs =====
+// ======================================================================
+
+const NOWPLAYING_STATE = "nowplaying.json"
+const DEFAULT_NOWPLAYING_FORMAT = "{{.Artist}} - {{.Title}} [{{.Progress}}]"
+const NOWPLAYING_REFRESH = 2 * time.Second // While playing, sinks are rewritten at most this often so Progress keeps up
+
+// NowPlaying is the exported view of the playback state, used by every sink and template.
+type NowPlaying struct {
+	Title      string    `json:"title"`
+	Artist     string    `json:"artist"`
+	Album      string    `json:"album"`
+	URI        string    `json:"uri"`
+	CoverURL   string    `json:"cover_url"`
+	Status     string    `json:"status"`
+	IsPlaying  bool      `json:"is_playing"`
+	Shuffle    bool      `json:"shuffle"`
+	ProgressMs int       `json:"progress_ms"`
+	DurationMs int       `json:"duration_ms"`
+	Progress   string    `json:"progress"`
+	Duration   string    `json:"duration"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// NowPlayingExporter writes the current track to the configured sinks whenever the track or state changes,
+// and every NOWPLAYING_REFRESH while playing.
+type NowPlayingExporter struct {
+	statePath string
+	textPath  string
+	format    *template.Template
+	coverPath string
+	lastKey   string
+	lastCover string
+	lastWrite time.Time
+}
+
+// newNowPlaying builds a NowPlaying from a playback state.
+func newNowPlaying(state PlaybackState, now time.Time) NowPlaying {
+	status := "paused"
+	if state.IsPlaying {
+		status = "playing"
+	}
+	display := state.display()
+	return NowPlaying{
+		Title:      display.Title,
+		Artist:     strings.Join(display.Artists, ", "),
+		Album:      display.Subtitle,
+		URI:        display.URI,
+		CoverURL:   display.Image,
+		Status:     status,
+		IsPlaying:  state.IsPlaying,
+		Shuffle:    state.ShuffleState,
+		ProgressMs: state.ProgressMs,
+		DurationMs: state.Item.DurationMs,
+		Progress:   msToMinSec(state.ProgressMs),
+		Duration:   msToMinSec(state.Item.DurationMs),
+		UpdatedAt:  now,
+	}
+}
+
+// advance moves a paused-in-time snapshot forward to now, so readers see live progress.
+func (np NowPlaying) advance(now time.Time) NowPlaying {
+	if np.IsPlaying {
+		np.ProgressMs = min(np.ProgressMs+int(now.Sub(np.UpdatedAt).Milliseconds()), np.DurationMs)
+		np.Progress = msToMinSec(np.ProgressMs)
+		np.UpdatedAt = now
+	}
+	return np
+}
+
+// newNowPlayingExporter builds an exporter from the environment.
+// The state file used by `juketui status` is always written; the text and cover sinks are optional.
+func newNowPlayingExporter() *NowPlayingExporter {
+	exporter := &NowPlayingExporter{
+		statePath: dataPath(NOWPLAYING_STATE),
+		textPath:  os.Getenv("NOWPLAYING_FILE"),
+		coverPath: os.Getenv("NOWPLAYING_COVER"),
+	}
+	format, err := template.New("nowplaying").Parse(queryEnv("NOWPLAYING_FORMAT", DEFAULT_NOWPLAYING_FORMAT))
+	if err != nil {
+		errorLogger.Printf("Invalid NOWPLAYING_FORMAT, using default: %v", err)
+		format = template.Must(template.New("nowplaying").Parse(DEFAULT_NOWPLAYING_FORMAT))
+	}
+	exporter.format = format
+	return exporter
+}
+
+// exportCmd returns a command that updates every sink, or nil if nothing worth exporting has changed.
+// Progress alone counts as a change once NOWPLAYING_REFRESH has passed since the last write.
+//
+// Parameters:
+// - ctx: Cancels the cover download when the app quits.
+// - state: The latest playback state.
+//
+// Returns:
+// - A command writing the sinks, or nil.
+func (e *NowPlayingExporter) exportCmd(ctx context.Context, state PlaybackState) tea.Cmd {
+	if e == nil || state.Item.URI == "" {
+		return nil
+	}
+	now := time.Now()
+	key := fmt.Sprintf("%s|%t|%t", state.Item.URI, state.IsPlaying, state.ShuffleState)
+	if key == e.lastKey && (!state.IsPlaying || now.Sub(e.lastWrite) < NOWPLAYING_REFRESH) {
+		return nil
+	}
+	e.lastKey, e.lastWrite = key, now
+
+	np := newNowPlaying(state, now)
+	fetchCover := e.coverPath != "" && np.CoverURL != "" && np.CoverURL != e.lastCover
+	if fetchCover {
+		e.lastCover = np.CoverURL
+	}
+	return func() tea.Msg {
+		if err := e.write(np); err != nil {
+			errorLogger.Println("Failed to export now playing: ", err)
+		}
+		if fetchCover {
+			if err := downloadFile(ctx, np.CoverURL, e.coverPath); err != nil {
+				errorLogger.Println("Failed to export cover image: ", err)
+			}
+		}
+		return nil
+	}
+}
+
+// write renders a NowPlaying to the state file and the optional text/JSON sink.
+func (e *NowPlayingExporter) write(np NowPlaying) error {
+	data, err := json.MarshalIndent(np, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(e.statePath, data); err != nil {
+		return err
+	}
+	if e.textPath == "" {
+		return nil
+	}
+	if strings.HasSuffix(e.textPath, ".json") {
+		return writeFileAtomic(e.textPath, data)
+	}
+	var text strings.Builder
+	if err := e.format.Execute(&text, np); err != nil {
+		return err
+	}
+	return writeFileAtomic(e.textPath, []byte(text.String()+"\n"))
+}
+
+// clear empties every sink, so status bars stop showing a track once JukeTUI exits.
+func (e *NowPlayingExporter) clear() {
+	if e == nil {
+		return
+	}
+	os.Remove(e.statePath)
+	if e.textPath != "" {
+		writeFileAtomic(e.textPath, nil)
+	}
+}
+
+// downloadFile fetches a URL into a file atomically.
+func downloadFile(ctx context.Context, url, path string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := webClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d fetching %s", resp.StatusCode, url)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// runStatus implements `juketui status`: print the current track once, for tmux, polybar, waybar and friends.
+// Prints an empty line when nothing is playing, which status bars treat as "hide".
+func runStatus(args []string) {
+	flags := flag.NewFlagSet("status", flag.ExitOnError)
+	format := flags.String("format", queryEnv("NOWPLAYING_FORMAT", DEFAULT_NOWPLAYING_FORMAT), "Go template for the output, e.g. '{{.Artist}} - {{.Title}}'")
+	asJSON := flags.Bool("json", false, "Print the full state as JSON instead of using --format")
+	flags.Parse(args)
+
+	data, err := os.ReadFile(dataPath(NOWPLAYING_STATE))
+	if err != nil {
+		fmt.Println()
+		return
+	}
+	var np NowPlaying
+	if err := json.Unmarshal(data, &np); err != nil {
+		fmt.Fprintln(os.Stderr, "Unreadable now playing state: ", err)
+		os.Exit(1)
+	}
+	np = np.advance(time.Now())
+
+	if *asJSON {
+		out, _ := json.Marshal(np)
+		fmt.Println(string(out))
+		return
+	}
+	tmpl, err := template.New("status").Parse(*format)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid format: ", err)
+		os.Exit(1)
+	}
+	if err := tmpl.Execute(os.Stdout, np); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to render status: ", err)
+		os.Exit(1)
+	}
+	fmt.Println()
+}
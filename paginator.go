@@ -0,0 +1,113 @@
+package main
+
+import tea "github.com/charmbracelet/bubbletea"
+
+// =====================================================
+// ===== paginator.go | Paging through the library =====
+// =====================================================
+
+// Paginator tracks which page of a library is shown.
+// Favorites pinned above the page take rows away from it, and are never counted in the total,
+// so every page is full (apart from the last) and no item shows up on two pages.
+type Paginator struct {
+	rows   int // Rows the library has for items, pinned favorites included
+	pinned int // Rows taken by favorites pinned above the page
+	offset int // Index of the first item on the page, always a multiple of the page size
+	total  int // Items to page through, not counting pinned favorites
+}
+
+// PageSize gets the number of items on a full page.
+func (p Paginator) PageSize() int {
+	return max(1, p.rows-p.pinned)
+}
+
+// Offset gets the index of the first item on the page.
+func (p Paginator) Offset() int {
+	return p.offset
+}
+
+// Page gets the index of the current page, starting at 0.
+func (p Paginator) Page() int {
+	return p.offset / p.PageSize()
+}
+
+// Pages gets the number of pages. An empty library still has one (empty) page.
+func (p Paginator) Pages() int {
+	return max(1, (p.total+p.PageSize()-1)/p.PageSize())
+}
+
+// Resize changes the rows available and the number of pinned favorites,
+// keeping the first item of the current page in view.
+func (p Paginator) Resize(rows, pinned int) Paginator {
+	p.rows, p.pinned = rows, pinned
+	return p.Jump(p.offset / p.PageSize())
+}
+
+// WithTotal sets the number of items to page through, moving to the last page if the current one is gone.
+func (p Paginator) WithTotal(total int) Paginator {
+	p.total = max(total, 0)
+	return p.Jump(p.Page())
+}
+
+// Jump moves to a page, clamped to the first and last pages.
+func (p Paginator) Jump(page int) Paginator {
+	page = min(max(page, 0), p.Pages()-1)
+	p.offset = page * p.PageSize()
+	return p
+}
+
+// Next moves to the next page, wrapping around to the first.
+func (p Paginator) Next() Paginator {
+	return p.Jump((p.Page() + 1) % p.Pages())
+}
+
+// Prev moves to the previous page, wrapping around to the last.
+func (p Paginator) Prev() Paginator {
+	return p.Jump((p.Page() + p.Pages() - 1) % p.Pages())
+}
+
+// First moves to the first page.
+func (p Paginator) First() Paginator {
+	return p.Jump(0)
+}
+
+// Last moves to the last page.
+func (p Paginator) Last() Paginator {
+	return p.Jump(p.Pages() - 1)
+}
+
+// turnPage moves whatever the library pane shows (the library, an artist's discography or a show's episodes) to another page.
+func (m Model) turnPage(move func(Paginator) Paginator) (Model, tea.Cmd) {
+	switch {
+	case m.artist != nil:
+		return m.pageArtist(move(m.artist.pager))
+	case m.show != nil:
+		return m.pageShow(move(m.show.pager))
+	}
+	m.pager = move(m.libraryPager())
+	return m.reloadLibrary()
+}
+
+// pageItems cuts the current page out of a full list, leaving out excluded items (usually pinned favorites).
+//
+// Parameters:
+// - p: The paginator. Its total is replaced by the number of items kept.
+// - items: Every item in the library.
+// - excluded: Reports items that aren't paged, or nil to keep everything.
+//
+// Returns:
+// - The items on the page.
+// - The number of items kept, to pass to WithTotal.
+//
+// Type parameters:
+// - T: The type of one item.
+func pageItems[T any](p Paginator, items []T, excluded func(T) bool) ([]T, int) {
+	kept := make([]T, 0, len(items))
+	for _, item := range items {
+		if excluded == nil || !excluded(item) {
+			kept = append(kept, item)
+		}
+	}
+	p = p.WithTotal(len(kept))
+	return kept[p.offset:min(len(kept), p.offset+p.PageSize())], len(kept)
+}
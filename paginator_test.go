@@ -0,0 +1,105 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+// fakeLibrary is a library of n numbered items.
+func fakeLibrary(n int) []int {
+	items := make([]int, n)
+	for i := range items {
+		items[i] = i
+	}
+	return items
+}
+
+func TestPaginatorWithTotal(t *testing.T) {
+	tests := []struct {
+		name      string
+		pager     Paginator
+		total     int
+		wantPages int
+		wantPage  int
+	}{
+		{name: "empty library", pager: Paginator{}.Resize(10, 0), total: 0, wantPages: 1, wantPage: 0},
+		{name: "negative total", pager: Paginator{}.Resize(10, 0), total: -5, wantPages: 1, wantPage: 0},
+		{name: "exactly one page", pager: Paginator{}.Resize(10, 0), total: 10, wantPages: 1, wantPage: 0},
+		{name: "last page partial", pager: Paginator{}.Resize(10, 0), total: 25, wantPages: 3, wantPage: 0},
+		{name: "pinned favorites shrink pages", pager: Paginator{}.Resize(10, 4), total: 25, wantPages: 5, wantPage: 0},
+		{name: "shrinking keeps the page", pager: Paginator{}.Resize(10, 0).WithTotal(50).Jump(2), total: 40, wantPages: 4, wantPage: 2},
+		{name: "current page gone", pager: Paginator{}.Resize(10, 0).WithTotal(50).Last(), total: 21, wantPages: 3, wantPage: 2},
+		{name: "no rows still pages", pager: Paginator{}.Resize(0, 0), total: 3, wantPages: 3, wantPage: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.pager.WithTotal(tt.total)
+			if p.Pages() != tt.wantPages || p.Page() != tt.wantPage {
+				t.Errorf("page %d of %d, want %d of %d", p.Page(), p.Pages(), tt.wantPage, tt.wantPages)
+			}
+			if p.Offset() != p.Page()*p.PageSize() {
+				t.Errorf("offset %d is not the start of page %d", p.Offset(), p.Page())
+			}
+		})
+	}
+}
+
+func TestPaginatorMoves(t *testing.T) {
+	pager := Paginator{}.Resize(10, 0).WithTotal(25) // Pages of 10, 10 and 5
+	tests := []struct {
+		name string
+		move func(Paginator) Paginator
+		from int
+		want int
+	}{
+		{name: "next", move: Paginator.Next, from: 0, want: 1},
+		{name: "next wraps to first", move: Paginator.Next, from: 2, want: 0},
+		{name: "prev", move: Paginator.Prev, from: 2, want: 1},
+		{name: "prev wraps to last", move: Paginator.Prev, from: 0, want: 2},
+		{name: "first", move: Paginator.First, from: 2, want: 0},
+		{name: "last", move: Paginator.Last, from: 0, want: 2},
+		{name: "jump", move: func(p Paginator) Paginator { return p.Jump(1) }, from: 0, want: 1},
+		{name: "jump past the end clamps", move: func(p Paginator) Paginator { return p.Jump(99) }, from: 0, want: 2},
+		{name: "jump before the start clamps", move: func(p Paginator) Paginator { return p.Jump(-4) }, from: 2, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.move(pager.Jump(tt.from)).Page(); got != tt.want {
+				t.Errorf("page %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	empty := Paginator{}.Resize(10, 0).WithTotal(0)
+	for name, move := range map[string]func(Paginator) Paginator{"next": Paginator.Next, "prev": Paginator.Prev, "last": Paginator.Last} {
+		if got := move(empty); got.Page() != 0 || got.Offset() != 0 {
+			t.Errorf("%s on an empty library moved to page %d", name, got.Page())
+		}
+	}
+}
+
+func TestPageItems(t *testing.T) {
+	odd := func(i int) bool { return i%2 == 1 }
+	tests := []struct {
+		name      string
+		pager     Paginator
+		items     []int
+		excluded  func(int) bool
+		want      []int
+		wantTotal int
+	}{
+		{name: "first page", pager: Paginator{}.Resize(3, 0), items: fakeLibrary(7), want: []int{0, 1, 2}, wantTotal: 7},
+		{name: "last partial page", pager: Paginator{}.Resize(3, 0).WithTotal(7).Last(), items: fakeLibrary(7), want: []int{6}, wantTotal: 7},
+		{name: "empty library", pager: Paginator{}.Resize(3, 0), items: nil, want: []int{}, wantTotal: 0},
+		{name: "excluded items aren't paged", pager: Paginator{}.Resize(3, 0).WithTotal(10).Jump(1), items: fakeLibrary(10), excluded: odd, want: []int{6, 8}, wantTotal: 5},
+		{name: "page gone after excluding", pager: Paginator{}.Resize(3, 0).WithTotal(10).Last(), items: fakeLibrary(10), excluded: odd, want: []int{6, 8}, wantTotal: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, total := pageItems(tt.pager, tt.items, tt.excluded)
+			if !slices.Equal(got, tt.want) || total != tt.wantTotal {
+				t.Errorf("got %v of %d, want %v of %d", got, total, tt.want, tt.wantTotal)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"time"
+)
+
+// ==============================================================
+// ===== playbackClock.go | Progress between playback polls =====
+// ==============================================================
+
+const (
+	CLOCK_SNAP_MS   = 1500                   // Bigger disagreements with the API are seeks or skips, and jump straight there
+	CLOCK_SLEW      = 2 * time.Second        // Smaller ones are eased out over this long, so progress never runs backwards
+	TRACK_END_SLACK = 300 * time.Millisecond // Poll this long after the item should end, so the API has moved on
+)
+
+// PlaybackClock works out the progress of the playing item at any moment from the last playback state.
+// It is anchored to where the API said playback was and the (monotonic) time it said so, and
+// interpolated from there when rendering. Like Paginator, it is a value; its methods return the changed copy.
+type PlaybackClock struct {
+	uri          string
+	durationMs   int
+	playing      bool
+	anchorMs     int       // Progress when the anchoring state was sampled
+	anchorAt     time.Time // When that was, with a monotonic reading so wall clock changes don't move progress
+	timestamp    int64     // The API's timestamp of the anchoring state, to drop responses that arrive out of order
+	correctionMs int       // How far ahead of the API we were when anchoring, eased out over CLOCK_SLEW
+}
+
+// sync anchors the clock to a fresh playback state. Small corrections to a playing item are eased in.
+//
+// Parameters:
+// - state: The playback state from the API.
+// - sampledAt: When the API sampled it, best guess halfway through the request. Zero means now.
+//
+// Returns:
+// - The clock anchored to state, or the clock as it was if state is older than its anchor.
+func (c PlaybackClock) sync(state PlaybackState, sampledAt time.Time) PlaybackClock {
+	if state.Item.URI == c.uri && state.Timestamp < c.timestamp {
+		return c
+	}
+	if sampledAt.IsZero() {
+		sampledAt = time.Now()
+	}
+	next := PlaybackClock{
+		uri:        state.Item.URI,
+		durationMs: state.Item.DurationMs,
+		playing:    state.IsPlaying,
+		anchorMs:   state.ProgressMs,
+		anchorAt:   sampledAt,
+		timestamp:  state.Timestamp,
+	}
+	if c.playing && next.playing && c.uri == next.uri {
+		if drift := c.progress(sampledAt) - state.ProgressMs; drift > -CLOCK_SNAP_MS && drift < CLOCK_SNAP_MS {
+			next.correctionMs = drift
+		}
+	}
+	return next
+}
+
+// progress gets the progress of the playing item at a moment, in ms, within the item's length.
+func (c PlaybackClock) progress(now time.Time) int {
+	if c.anchorAt.IsZero() {
+		return 0
+	}
+	progress := c.anchorMs
+	if c.playing {
+		elapsed := now.Sub(c.anchorAt)
+		progress += int(elapsed.Milliseconds())
+		if elapsed < CLOCK_SLEW {
+			progress += int(int64(c.correctionMs) * int64(CLOCK_SLEW-elapsed) / int64(CLOCK_SLEW))
+		}
+	}
+	return max(0, min(progress, c.durationMs))
+}
+
+// seek moves the clock to a position, as when the user seeks, ahead of the API catching up.
+func (c PlaybackClock) seek(positionMs int, now time.Time) PlaybackClock {
+	c.anchorMs, c.anchorAt, c.correctionMs = positionMs, now, 0
+	return c
+}
+
+// setPlaying pauses or resumes the clock where it is, as when the user presses play/pause.
+func (c PlaybackClock) setPlaying(playing bool, now time.Time) PlaybackClock {
+	c.anchorMs, c.anchorAt, c.correctionMs = c.progress(now), now, 0
+	c.playing = playing
+	return c
+}
+
+// endsIn gets how long until the playing item ends, and false if nothing is playing.
+func (c PlaybackClock) endsIn(now time.Time) (time.Duration, bool) {
+	if !c.playing || c.durationMs <= 0 {
+		return 0, false
+	}
+	return time.Duration(c.durationMs-c.progress(now)) * time.Millisecond, true
+}
+
+// progressMs gets the progress of the playing item right now.
+func (m Model) progressMs() int {
+	return m.clock.progress(time.Now())
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+var clockStart = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+// at is a moment some milliseconds after clockStart.
+func at(ms int) time.Time {
+	return clockStart.Add(time.Duration(ms) * time.Millisecond)
+}
+
+// playing is a playback state of a 60 second item.
+func playing(uri string, progressMs int, isPlaying bool, timestamp int64) PlaybackState {
+	state := PlaybackState{IsPlaying: isPlaying, ProgressMs: progressMs, Timestamp: timestamp}
+	state.Item.URI, state.Item.DurationMs = uri, 60000
+	return state
+}
+
+func TestPlaybackClockProgress(t *testing.T) {
+	anchored := PlaybackClock{}.sync(playing("spotify:track:a", 10000, true, 100), at(0))
+	tests := []struct {
+		name  string
+		clock PlaybackClock
+		now   int
+		want  int
+	}{
+		{name: "never synced", clock: PlaybackClock{}, now: 5000, want: 0},
+		{name: "runs from the anchor", clock: anchored, now: 1000, want: 11000},
+		{name: "paused stands still", clock: PlaybackClock{}.sync(playing("spotify:track:a", 10000, false, 100), at(0)), now: 5000, want: 10000},
+		{name: "stops at the end", clock: PlaybackClock{}.sync(playing("spotify:track:a", 59000, true, 100), at(0)), now: 5000, want: 60000},
+		{name: "small drift is eased in, not jumped", clock: anchored.sync(playing("spotify:track:a", 10500, true, 200), at(1000)), now: 1000, want: 11000},
+		{name: "halfway through easing", clock: anchored.sync(playing("spotify:track:a", 10500, true, 200), at(1000)), now: 2000, want: 11750},
+		{name: "eased out after the slew", clock: anchored.sync(playing("spotify:track:a", 10500, true, 200), at(1000)), now: 3000, want: 12500},
+		{name: "running behind is eased too", clock: anchored.sync(playing("spotify:track:a", 12000, true, 200), at(1000)), now: 1000, want: 11000},
+		{name: "big drift is a seek and snaps", clock: anchored.sync(playing("spotify:track:a", 30000, true, 200), at(1000)), now: 1000, want: 30000},
+		{name: "drift just under the snap eases", clock: anchored.sync(playing("spotify:track:a", 11000-CLOCK_SNAP_MS+1, true, 200), at(1000)), now: 1000, want: 11000},
+		{name: "drift at the snap jumps", clock: anchored.sync(playing("spotify:track:a", 11000-CLOCK_SNAP_MS, true, 200), at(1000)), now: 1000, want: 11000 - CLOCK_SNAP_MS},
+		{name: "new item starts fresh", clock: anchored.sync(playing("spotify:track:b", 10500, true, 200), at(1000)), now: 1000, want: 10500},
+		{name: "resuming doesn't ease", clock: anchored.setPlaying(false, at(0)).sync(playing("spotify:track:a", 10500, true, 200), at(1000)), now: 1000, want: 10500},
+		{name: "older response dropped", clock: anchored.sync(playing("spotify:track:a", 40000, true, 50), at(1000)), now: 1000, want: 11000},
+		{name: "older response for another item kept", clock: anchored.sync(playing("spotify:track:b", 40000, true, 50), at(1000)), now: 1000, want: 40000},
+		{name: "a correction backwards holds rather than rewinding", clock: PlaybackClock{}.sync(playing("spotify:track:a", 1000, true, 100), at(0)).sync(playing("spotify:track:a", 0, true, 200), at(0)), now: 0, want: 1000},
+		{name: "seek", clock: anchored.sync(playing("spotify:track:a", 10500, true, 200), at(1000)).seek(45000, at(1000)), now: 2000, want: 46000},
+		{name: "pause holds where it was", clock: anchored.setPlaying(false, at(2000)), now: 9000, want: 12000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.clock.progress(at(tt.now)); got != tt.want {
+				t.Errorf("progress %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlaybackClockEndsIn(t *testing.T) {
+	tests := []struct {
+		name   string
+		clock  PlaybackClock
+		want   time.Duration
+		wantOK bool
+	}{
+		{name: "playing", clock: PlaybackClock{}.sync(playing("spotify:track:a", 50000, true, 100), at(0)), want: 8 * time.Second, wantOK: true},
+		{name: "past the end", clock: PlaybackClock{}.sync(playing("spotify:track:a", 59000, true, 100), at(0)), want: 0, wantOK: true},
+		{name: "paused", clock: PlaybackClock{}.sync(playing("spotify:track:a", 50000, false, 100), at(0)), wantOK: false},
+		{name: "nothing playing", clock: PlaybackClock{}, wantOK: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.clock.endsIn(at(2000))
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("got %v (%v), want %v (%v)", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// =================================================================
+// ===== player.go | Playback commands, shown before they land =====
+// =================================================================
+
+// playerSnapshot is what an optimistic playback command changed, to put back if the command fails.
+type playerSnapshot struct {
+	state PlaybackState
+	clock PlaybackClock
+	queue Queue
+}
+
+// playerCmdMsg reports how a playback command went.
+type playerCmdMsg struct {
+	action       string // What was attempted, e.g. "pause", for the error toast
+	err          error
+	rollback     playerSnapshot
+	refreshQueue bool // The command moved the queue along, so fetch it again
+}
+
+// coverMsg carries a rendered cover image, fetched off the UI thread.
+type coverMsg struct {
+	url   string
+	image string
+}
+
+// snapshot records the parts of the model a playback command may change optimistically.
+func (m Model) snapshot() playerSnapshot {
+	return playerSnapshot{state: m.state, clock: m.clock, queue: m.queue}
+}
+
+// playerCmd returns a command that sends a playback command off the UI thread.
+//
+// Parameters:
+// - action: What is being done, e.g. "pause", for the error toast.
+// - rollback: The model as it was before the optimistic update.
+// - send: Sends the request, returning its status code.
+//
+// Returns:
+// - A command returning playerCmdMsg.
+func playerCmd(action string, rollback playerSnapshot, send func() (int, error)) tea.Cmd {
+	return func() tea.Msg {
+		_, err := send()
+		return playerCmdMsg{action: action, err: err, rollback: rollback, refreshQueue: action == "skip"}
+	}
+}
+
+// startPlay starts playing something off the UI thread, like the other playback commands.
+// Nothing is shown optimistically, as we don't know the item yet: the poll after the command picks it up.
+//
+// Parameters:
+// - action: What is being done, e.g. "play", for the error toast.
+// - play: Sends the request, given the context, token and device to play on.
+//
+// Returns:
+// - The updated model, and a command returning playerCmdMsg.
+func (m Model) startPlay(action string, play func(ctx context.Context, token, deviceID string) error) (Model, tea.Cmd) {
+	rollback := m.snapshot()
+	m.pendingCommands++
+	ctx, token, deviceID := m.ctx, m.token, m.state.Device.ID
+	return m, playerCmd(action, rollback, func() (int, error) {
+		return 0, play(ctx, token, deviceID)
+	})
+}
+
+// togglePlay pauses or resumes playback, showing the change straight away.
+func (m Model) togglePlay() (Model, tea.Cmd) {
+	rollback := m.snapshot()
+	m.state.IsPlaying = !m.state.IsPlaying
+	m.clock = m.clock.setPlaying(m.state.IsPlaying, time.Now())
+	m.pendingCommands++
+	ctx, token, deviceID := m.ctx, m.token, m.state.Device.ID
+	if !m.state.IsPlaying {
+		return m, playerCmd("pause", rollback, func() (int, error) {
+			return handleGenericPut(ctx, "/me/player/pause", token, nil, nil)
+		})
+	}
+	return m, playerCmd("play", rollback, func() (int, error) {
+		return handleGenericPut(ctx, "/me/player/play", token, nil, map[string]any{"device_id": deviceID})
+	})
+}
+
+// skip moves on to the next item, showing the first queued item straight away if we know it.
+func (m Model) skip() (Model, tea.Cmd) {
+	rollback := m.snapshot()
+	m.pendingCommands++
+	ctx, token := m.ctx, m.token
+	cmd := playerCmd("skip", rollback, func() (int, error) {
+		return handleGenericPost(ctx, "/me/player/next", token, nil, nil)
+	})
+	if len(m.queue.Queue) == 0 {
+		return m, cmd
+	}
+	m.state.Item, m.state.ProgressMs = m.queue.Queue[0], 0
+	m.queue.Queue = m.queue.Queue[1:]
+	m.clock = PlaybackClock{}.sync(m.state, time.Now())
+	m, itemCmd := m.showItem()
+	return m, tea.Batch(cmd, itemCmd)
+}
+
+// toggleShuffle turns shuffle on or off, showing the change straight away.
+func (m Model) toggleShuffle() (Model, tea.Cmd) {
+	rollback := m.snapshot()
+	m.state.ShuffleState = !m.state.ShuffleState
+	m.pendingCommands++
+	ctx, token, shuffle := m.ctx, m.token, m.state.ShuffleState
+	return m, playerCmd("toggle shuffle", rollback, func() (int, error) {
+		return handleGenericPut(ctx, "/me/player/shuffle", token, map[string]string{"state": fmt.Sprintf("%t", shuffle)}, nil)
+	})
+}
+
+// seekTo seeks to the point of the playing item under a cell of the progress bar.
+func (m Model) seekTo(cell, barWidth int) (Model, tea.Cmd) {
+	if cell < 0 || cell >= barWidth || m.state.Item.DurationMs <= 0 {
+		return m, nil
+	}
+	rollback := m.snapshot()
+	positionMs := cell * m.state.Item.DurationMs / barWidth
+	m.clock = m.clock.seek(positionMs, time.Now())
+	m.pendingCommands++
+	ctx, token := m.ctx, m.token
+	return m, playerCmd("seek", rollback, func() (int, error) {
+		return handleSeek(ctx, token, positionMs)
+	})
+}
+
+// playerDone settles a playback command: on failure the optimistic update is rolled back and a toast says why.
+// Either way, the next poll comes soon to confirm what the player is really doing.
+// A command sent after the failed one has its own optimistic update on top of the snapshot, so while one is
+// still pending nothing is rolled back: the poll after the last command lands settles them all.
+func (m Model) playerDone(msg playerCmdMsg) (Model, tea.Cmd) {
+	m.pendingCommands = max(0, m.pendingCommands-1)
+	m.scheduler = m.scheduler.poke(time.Now())
+	if msg.err != nil {
+		m = m.notifyErr("Couldn't "+msg.action, msg.err)
+		var itemCmd tea.Cmd
+		if m.pendingCommands == 0 {
+			changed := m.state.Item.URI != msg.rollback.state.Item.URI
+			m.state, m.clock, m.queue = msg.rollback.state, msg.rollback.clock, msg.rollback.queue
+			if changed { // Bring the old item's cover back, its cover fetch may have been dropped meanwhile
+				m, itemCmd = m.showItem()
+			}
+		}
+		if apiStatus(msg.err) == http.StatusUnauthorized {
+			var tokenCmd tea.Cmd
+			m, tokenCmd = m.checkToken()
+			return m, tea.Batch(itemCmd, tokenCmd)
+		}
+		return m, itemCmd
+	}
+	if msg.refreshQueue {
+		return m, handleGetQueue(m.ctx, m.token)
+	}
+	return m, nil
+}
+
+// showItem catches the screen up with a new playing item: its cover, and its lyrics if they are shown.
+func (m Model) showItem() (Model, tea.Cmd) {
+	m.image = ""
+	var coverCmd tea.Cmd
+	if cover := m.state.display().Image; cover != "" { // Local files have no artwork
+		ctx := m.ctx
+		coverCmd = func() tea.Msg {
+			return coverMsg{url: cover, image: makeNewImage(ctx, cover)}
+		}
+	}
+	if m.showLyrics {
+		return m, tea.Batch(coverCmd, m.lyricsFinder.findCmd(m.ctx, newLyricsQuery(m.state)))
+	}
+	return m, coverCmd
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+// playerModel is a model playing one track with another queued after it.
+func playerModel() Model {
+	m := Model{}
+	m.state = playing("spotify:track:a", 10000, true, 100)
+	var next PlayableItem
+	next.URI, next.DurationMs = "spotify:track:b", 60000
+	m.queue.Queue = []PlayableItem{next}
+	return m
+}
+
+// sent is how a playback command went, failed or not.
+func sent(cmd playerCmdMsg, failed bool) playerCmdMsg {
+	if failed {
+		cmd.err = errors.New("no active device")
+	}
+	return cmd
+}
+
+func TestPlayerRollback(t *testing.T) {
+	tests := []struct {
+		name        string
+		shuffleFail bool
+		skipFail    bool
+		wantShuffle bool
+		wantItem    string
+	}{
+		{name: "both land", wantShuffle: true, wantItem: "spotify:track:b"},
+		{name: "earlier fails while a later one is pending", shuffleFail: true, wantShuffle: true, wantItem: "spotify:track:b"},
+		{name: "last one fails", skipFail: true, wantShuffle: true, wantItem: "spotify:track:a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := playerModel()
+			shuffle := playerCmdMsg{action: "toggle shuffle", rollback: m.snapshot()}
+			m, _ = m.toggleShuffle()
+			skip := playerCmdMsg{action: "skip", rollback: m.snapshot()}
+			m, _ = m.skip()
+
+			m, _ = m.playerDone(sent(shuffle, tt.shuffleFail))
+			if m.state.Item.URI != "spotify:track:b" {
+				t.Fatalf("settling the shuffle undid the pending skip, playing %q", m.state.Item.URI)
+			}
+			m, _ = m.playerDone(sent(skip, tt.skipFail))
+			if m.pendingCommands != 0 || m.state.ShuffleState != tt.wantShuffle || m.state.Item.URI != tt.wantItem {
+				t.Errorf("%d pending, shuffle %v, playing %q; want none, %v, %q", m.pendingCommands, m.state.ShuffleState, m.state.Item.URI, tt.wantShuffle, tt.wantItem)
+			}
+		})
+	}
+}
+
+func TestPlayerRollbackAlone(t *testing.T) {
+	m := playerModel()
+	pause := playerCmdMsg{action: "pause", rollback: m.snapshot()}
+	m, _ = m.togglePlay()
+	if m.state.IsPlaying {
+		t.Fatal("pause didn't show straight away")
+	}
+	m, _ = m.playerDone(sent(pause, true))
+	if !m.state.IsPlaying || m.toast.Level != NOTICE_ERROR {
+		t.Errorf("failed pause left playing %v and toast %+v", m.state.IsPlaying, m.toast)
+	}
+}
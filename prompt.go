@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// =====================================================
+// ===== prompt.go | Text input inside the library =====
+// =====================================================
+
+// Kinds of library prompt.
+const (
+	PROMPT_TAGS   = "Tags"
+	PROMPT_GROUP  = "Group"
+	PROMPT_SLOT   = "Slot (1-9, empty to clear)"
+	PROMPT_PAGE   = "Go to page"
+	PROMPT_SEARCH = "Search"
+)
+
+// libraryPrompt is a one line text input shown at the bottom of the library.
+type libraryPrompt struct {
+	kind  string // "" when no prompt is open
+	uri   string // The library item the prompt is about
+	value string
+}
+
+// openPrompt opens a prompt about the library item under the cursor, starting with a value.
+func (m Model) openPrompt(kind, value string) Model {
+	if len(m.libraryList) == 0 {
+		return m
+	}
+	m.prompt = libraryPrompt{kind: kind, uri: m.libraryList[m.cursor].uri, value: value}
+	return m
+}
+
+// updatePrompt handles a key press while a prompt is open. Enter submits and Esc cancels.
+// The search prompt filters the library as you type, and Up/Down move through the results.
+func (m Model) updatePrompt(msg tea.KeyMsg) (Model, tea.Cmd) {
+	search := m.prompt.kind == PROMPT_SEARCH
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.prompt = libraryPrompt{}
+		if search && m.search != "" {
+			m.search, m.cursor = "", 0
+			m.pager = m.pager.First()
+			return m.reloadLibrary()
+		}
+		return m, nil
+
+	case tea.KeyUp, tea.KeyDown:
+		if search && len(m.libraryList) > 0 {
+			step := map[bool]int{true: -1, false: 1}[msg.Type == tea.KeyUp]
+			m.cursor = (m.cursor + step + len(m.libraryList)) % len(m.libraryList)
+		}
+		return m, nil
+
+	case tea.KeyEnter:
+		prompt := m.prompt
+		m.prompt = libraryPrompt{}
+		return m.submitPrompt(prompt)
+
+	case tea.KeyBackspace:
+		if runes := []rune(m.prompt.value); len(runes) > 0 {
+			m.prompt.value = string(runes[:len(runes)-1])
+		}
+
+	case tea.KeyRunes, tea.KeySpace:
+		m.prompt.value += string(msg.Runes)
+	}
+	if search && m.prompt.value != m.search {
+		m.search, m.cursor = m.prompt.value, 0
+		m.pager = m.pager.First()
+		return m.reloadLibrary()
+	}
+	return m, nil
+}
+
+// submitPrompt applies a finished prompt to the favorites store, or jumps to a page.
+func (m Model) submitPrompt(prompt libraryPrompt) (Model, tea.Cmd) {
+	var err error
+	switch prompt.kind {
+	case PROMPT_SEARCH: // Already applied while typing, Enter just closes the prompt
+		return m, nil
+	case PROMPT_PAGE:
+		page, err := strconv.Atoi(strings.TrimSpace(prompt.value))
+		if err != nil {
+			return m.notify(NOTICE_ERROR, "Page must be a number"), nil
+		}
+		return m.turnPage(func(p Paginator) Paginator { return p.Jump(page - 1) })
+	case PROMPT_TAGS:
+		err = m.favoriteStore.Update(prompt.uri, func(f *LibraryFavorite) {
+			f.Tags = parseTags(prompt.value)
+		})
+	case PROMPT_GROUP:
+		err = m.favoriteStore.Update(prompt.uri, func(f *LibraryFavorite) {
+			f.Group = strings.TrimSpace(prompt.value)
+		})
+	case PROMPT_SLOT:
+		slot := 0
+		if value := strings.TrimSpace(prompt.value); value != "" {
+			if slot, err = strconv.Atoi(value); err != nil {
+				err = fmt.Errorf("slot must be a number between 1 and %d", MAX_SLOT)
+				break
+			}
+		}
+		err = m.favoriteStore.AssignSlot(prompt.uri, slot)
+	}
+	if err != nil {
+		return m.notifyErr("Failed to update favorite", err), nil
+	}
+	return m.reloadLibrary()
+}
+
+// parseTags splits comma separated tags, dropping blanks and duplicates.
+func parseTags(value string) []string {
+	var tags []string
+	seen := map[string]bool{}
+	for _, tag := range strings.Split(value, ",") {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// nextTag cycles the tag filter through every tag in use, then back to no filter.
+func nextTag(tags []string, current string) string {
+	for i, tag := range tags {
+		if tag == current {
+			if i+1 < len(tags) {
+				return tags[i+1]
+			}
+			return ""
+		}
+	}
+	if current == "" && len(tags) > 0 {
+		return tags[0]
+	}
+	return ""
+}
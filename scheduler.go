@@ -0,0 +1,119 @@
+package main
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ==========================================================
+// ===== scheduler.go | One tick loop, adaptive polling =====
+// ==========================================================
+
+const (
+	TICK          = 500 * time.Millisecond // The one tick loop: redraws progress and decides when to poll
+	POLL_SOON     = 300 * time.Millisecond // After a user action, so its effect shows up quickly
+	POLL_FAST     = 1 * time.Second        // For a while after a user action
+	POLL_NORMAL   = 2 * time.Second        // While something is playing
+	POLL_IDLE     = 10 * time.Second       // While paused or nothing is playing
+	POLL_BACKOFF  = 60 * time.Second       // Longest wait after repeated errors
+	POLL_TIMEOUT  = 5 * time.Second        // A poll taking longer is abandoned, the next one will catch up
+	FAST_WINDOW   = 5 * time.Second        // How long polling stays fast after a user action
+	MAX_BACKOFF_X = 5                      // Errors after which the backoff stops doubling
+)
+
+// tickMsg is the tick of the one loop driving progress redraws and playback polls.
+type tickMsg struct{}
+
+// playbackFailedMsg reports a playback poll that failed.
+type playbackFailedMsg struct {
+	err error // An *APIError if Spotify answered
+}
+
+// playbackIdleMsg reports a playback poll that worked, but found no device playing anything.
+type playbackIdleMsg struct{}
+
+// Scheduler owns the playback poll cadence. Polls only start from the tick loop, and never while
+// another is in flight, so however many messages arrive there is exactly one poll loop.
+// Like Paginator, it is a value; its methods return the changed copy.
+type Scheduler struct {
+	nextPoll  time.Time
+	inFlight  bool
+	failures  int       // Polls failed in a row
+	fastUntil time.Time // Poll fast until then, after a user action
+}
+
+// tickCmd returns a command that ticks the loop once, after delay.
+func tickCmd(delay time.Duration) tea.Cmd {
+	return tea.Tick(delay, func(time.Time) tea.Msg { return tickMsg{} })
+}
+
+// due reports whether a poll should start now.
+func (s Scheduler) due(now time.Time) bool {
+	return !s.inFlight && !now.Before(s.nextPoll)
+}
+
+// started records that a poll is in flight. There must not be one already.
+func (s Scheduler) started() Scheduler {
+	if s.inFlight {
+		errorLogger.Println("Playback poll started while another was in flight")
+	}
+	s.inFlight = true
+	return s
+}
+
+// finished records the end of a poll and picks when the next one is due.
+//
+// Parameters:
+// - now: When the poll finished.
+// - clock: The playback clock, already synced to the poll's result.
+// - failed: Whether the poll failed. Failures back off exponentially.
+//
+// Returns:
+// - The updated scheduler.
+func (s Scheduler) finished(now time.Time, clock PlaybackClock, failed bool) Scheduler {
+	if !s.inFlight {
+		errorLogger.Println("Playback poll finished without being started")
+	}
+	s.inFlight = false
+	if failed {
+		s.failures++
+		s.nextPoll = now.Add(min(POLL_NORMAL<<min(s.failures, MAX_BACKOFF_X), POLL_BACKOFF))
+		return s
+	}
+	s.failures = 0
+
+	delay := POLL_IDLE
+	if remaining, playing := clock.endsIn(now); playing {
+		delay = POLL_NORMAL
+		if now.Before(s.fastUntil) {
+			delay = POLL_FAST
+		}
+		delay = max(min(delay, remaining+TRACK_END_SLACK), POLL_SOON) // Catch the next item as soon as it starts
+	} else if now.Before(s.fastUntil) {
+		delay = POLL_FAST
+	}
+	s.nextPoll = now.Add(delay)
+	return s
+}
+
+// poke speeds up polling after a user action: a poll soon, then fast polls for a while.
+func (s Scheduler) poke(now time.Time) Scheduler {
+	s.fastUntil = now.Add(FAST_WINDOW)
+	if soon := now.Add(POLL_SOON); soon.Before(s.nextPoll) {
+		s.nextPoll = soon
+	}
+	s.failures = 0 // The user is back, so give the API another go
+	return s
+}
+
+// tick advances the loop: redraw, start a poll if one is due, and schedule the next tick.
+func (m Model) tick() (Model, tea.Cmd) {
+	m.marquee++
+	cmds := []tea.Cmd{tickCmd(TICK)}
+	if m.scheduler.due(time.Now()) {
+		m.scheduler = m.scheduler.started()
+		cmds = append(cmds, handleFetchPlayback(m.ctx, m.token))
+	}
+	return m, tea.Batch(cmds...)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSchedulerBackoff(t *testing.T) {
+	want := []time.Duration{4 * time.Second, 8 * time.Second, 16 * time.Second, 32 * time.Second, POLL_BACKOFF, POLL_BACKOFF, POLL_BACKOFF}
+	s := Scheduler{}
+	for i, delay := range want {
+		s = s.started().finished(at(0), PlaybackClock{}, true)
+		if got := s.nextPoll.Sub(at(0)); got != delay {
+			t.Errorf("after %d failures waited %v, want %v", i+1, got, delay)
+		}
+	}
+
+	s = s.started().finished(at(0), PlaybackClock{}, false)
+	if s.failures != 0 || s.nextPoll.Sub(at(0)) != POLL_IDLE {
+		t.Errorf("a success left %d failures and a wait of %v", s.failures, s.nextPoll.Sub(at(0)))
+	}
+}
+
+func TestSchedulerCadence(t *testing.T) {
+	clockAt := func(progressMs int, isPlaying bool) PlaybackClock {
+		return PlaybackClock{}.sync(playing("spotify:track:a", progressMs, isPlaying, 100), at(0))
+	}
+	tests := []struct {
+		name  string
+		clock PlaybackClock
+		poked bool
+		want  time.Duration
+	}{
+		{name: "nothing playing", clock: PlaybackClock{}, want: POLL_IDLE},
+		{name: "paused", clock: clockAt(10000, false), want: POLL_IDLE},
+		{name: "paused after a user action", clock: clockAt(10000, false), poked: true, want: POLL_FAST},
+		{name: "playing", clock: clockAt(10000, true), want: POLL_NORMAL},
+		{name: "playing after a user action", clock: clockAt(10000, true), poked: true, want: POLL_FAST},
+		{name: "near the end", clock: clockAt(59000, true), want: time.Second + TRACK_END_SLACK},
+		{name: "at the end", clock: clockAt(60000, true), want: POLL_SOON},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := Scheduler{}
+			if tt.poked {
+				s = s.poke(at(0))
+			}
+			s = s.started().finished(at(0), tt.clock, false)
+			if got := s.nextPoll.Sub(at(0)); got != tt.want {
+				t.Errorf("next poll in %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchedulerFastWindowEnds(t *testing.T) {
+	s := Scheduler{}.poke(at(0)).started()
+	clock := PlaybackClock{}.sync(playing("spotify:track:a", 10000, true, 100), at(0))
+	if s = s.finished(at(int(FAST_WINDOW/time.Millisecond)), clock, false); s.nextPoll.Sub(at(int(FAST_WINDOW/time.Millisecond))) != POLL_NORMAL {
+		t.Errorf("still polling fast once the window is over")
+	}
+}
+
+func TestSchedulerPoke(t *testing.T) {
+	s := Scheduler{failures: 3, nextPoll: at(30000)}.poke(at(0))
+	if s.nextPoll != at(int(POLL_SOON/time.Millisecond)) || s.failures != 0 {
+		t.Errorf("poke left the next poll at %v with %d failures", s.nextPoll.Sub(at(0)), s.failures)
+	}
+	if s := (Scheduler{nextPoll: at(100)}).poke(at(0)); s.nextPoll != at(100) {
+		t.Errorf("poke put off a poll that was already sooner, to %v", s.nextPoll.Sub(at(0)))
+	}
+}
+
+// TestSchedulerOnePollInFlight drives the scheduler the way the tick loop does, with user actions and
+// slow polls mixed in, and checks a poll never starts while another is in flight.
+func TestSchedulerOnePollInFlight(t *testing.T) {
+	s := Scheduler{}
+	clock := PlaybackClock{}.sync(playing("spotify:track:a", 10000, true, 100), at(0))
+	inFlight, started := false, 0
+	for ms := 0; ms < 60000; ms += int(TICK / time.Millisecond) {
+		now := at(ms)
+		if ms%3500 == 0 {
+			s = s.poke(now) // A user action, in flight or not
+		}
+		if s.due(now) {
+			if inFlight {
+				t.Fatalf("at %dms a poll was due while another was in flight", ms)
+			}
+			s, inFlight = s.started(), true
+			started++
+			continue
+		}
+		if inFlight && ms%(7*int(TICK/time.Millisecond)) == 0 { // Polls take a few ticks to answer
+			s, inFlight = s.finished(now, clock, ms%2 == 0), false
+		}
+		if !inFlight && s.inFlight {
+			t.Fatalf("at %dms the scheduler thinks a poll is in flight when none is", ms)
+		}
+	}
+	if started < 2 {
+		t.Errorf("only %d polls started in a minute", started)
+	}
+
+	if (Scheduler{inFlight: true}).poke(at(0)).due(at(60000)) {
+		t.Error("a poll in flight, poked and long overdue, still must not start another")
+	}
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ===========================================================
+// ===== scrobbler.go | Last.fm and ListenBrainz support =====
+// ===========================================================
+
+const (
+	LISTENBRAINZ_URL     = "https://api.listenbrainz.org"
+	LASTFM_URL           = "https://ws.audioscrobbler.com/2.0/"
+	SCROBBLE_MIN_MS      = 30 * 1000     // Tracks shorter than this are never scrobbled
+	SCROBBLE_MAX_WAIT_MS = 4 * 60 * 1000 // Anything played this long is scrobbled regardless of length
+	SCROBBLE_JOURNAL     = "scrobbles.jsonl"
+)
+
+// scrobbleService is a site that can receive now-playing updates and scrobbles.
+type scrobbleService interface {
+	name() string
+	nowPlaying(ctx context.Context, l Listen) error
+	scrobble(ctx context.Context, l Listen) error
+}
+
+// Scrobbler sends listens to every configured service, journaling the ones that fail.
+type Scrobbler struct {
+	services    []scrobbleService
+	journalPath string
+	mu          sync.Mutex
+}
+
+// journalEntry is an unsent scrobble waiting in the journal.
+type journalEntry struct {
+	Service string `json:"service"`
+	Listen  Listen `json:"listen"`
+}
+
+// newScrobbler builds a scrobbler from the environment. Returns nil if no service is configured.
+func newScrobbler() *Scrobbler {
+	var services []scrobbleService
+	if token := os.Getenv("LISTENBRAINZ_TOKEN"); token != "" {
+		services = append(services, &listenBrainz{
+			baseURL: strings.TrimSuffix(queryEnv("LISTENBRAINZ_URL", LISTENBRAINZ_URL), "/"),
+			token:   token,
+		})
+	}
+	if key := os.Getenv("LASTFM_API_KEY"); key != "" {
+		services = append(services, &lastFM{
+			baseURL:    queryEnv("LASTFM_URL", LASTFM_URL),
+			apiKey:     key,
+			secret:     os.Getenv("LASTFM_SECRET"),
+			sessionKey: os.Getenv("LASTFM_SESSION_KEY"),
+		})
+	}
+	if len(services) == 0 {
+		return nil
+	}
+	return &Scrobbler{services: services, journalPath: dataPath(SCROBBLE_JOURNAL)}
+}
+
+// shouldScrobble applies the standard rules: over 30 seconds long, and played for half its length or 4 minutes.
+// Podcast episodes are never scrobbled.
+func shouldScrobble(l Listen) bool {
+	if l.DurationMs <= SCROBBLE_MIN_MS || uriKind(l.URI) == "episode" {
+		return false
+	}
+	return l.PlayedMs >= min(l.DurationMs/2, SCROBBLE_MAX_WAIT_MS)
+}
+
+// observeCmd returns a command that reports a started and/or finished listen to every service.
+//
+// Parameters:
+// - ctx: Cancels the requests when the app quits, journaling the unsent scrobbles.
+// - started: The listen that just started, or nil.
+// - finished: The listen that just finished, or nil.
+//
+// Returns:
+// - A command doing the network work, or nil if there is nothing to send.
+func (s *Scrobbler) observeCmd(ctx context.Context, started, finished *Listen) tea.Cmd {
+	if started != nil && uriKind(started.URI) == "episode" { // Not music, so not "now playing" either
+		started = nil
+	}
+	if s == nil || (started == nil && (finished == nil || !shouldScrobble(*finished))) {
+		return nil
+	}
+	return func() tea.Msg {
+		if finished != nil && shouldScrobble(*finished) {
+			for _, service := range s.services {
+				if err := service.scrobble(ctx, *finished); err != nil {
+					errorLogger.Printf("Failed to scrobble to %s, journaling: %v", service.name(), err)
+					s.journal(journalEntry{Service: service.name(), Listen: *finished})
+				}
+			}
+		}
+		if started != nil {
+			for _, service := range s.services {
+				if err := service.nowPlaying(ctx, *started); err != nil {
+					errorLogger.Printf("Failed to send now playing to %s: %v", service.name(), err)
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// journal appends an unsent scrobble to the journal file.
+func (s *Scrobbler) journal(entry journalEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		errorLogger.Println("Failed to marshal journal entry: ", err)
+		return
+	}
+	file, err := os.OpenFile(s.journalPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		errorLogger.Println("Failed to open scrobble journal: ", err)
+		return
+	}
+	defer file.Close()
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		errorLogger.Println("Failed to write scrobble journal: ", err)
+	}
+}
+
+// flushJournalCmd returns a command that retries every journaled scrobble, keeping the ones that still fail.
+func (s *Scrobbler) flushJournalCmd(ctx context.Context) tea.Cmd {
+	if s == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		file, err := os.Open(s.journalPath)
+		if err != nil {
+			return nil // Nothing journaled
+		}
+		var pending []journalEntry
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			var entry journalEntry
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+				errorLogger.Println("Dropping unreadable journal entry: ", err)
+				continue
+			}
+			pending = append(pending, entry)
+		}
+		file.Close()
+
+		services := map[string]scrobbleService{}
+		for _, service := range s.services {
+			services[service.name()] = service
+		}
+
+		var remaining bytes.Buffer
+		kept := 0
+		for _, entry := range pending {
+			service, ok := services[entry.Service]
+			if ok {
+				if err := service.scrobble(ctx, entry.Listen); err == nil {
+					continue
+				}
+			}
+			data, _ := json.Marshal(entry)
+			remaining.Write(append(data, '\n'))
+			kept++
+		}
+		if err := os.WriteFile(s.journalPath, remaining.Bytes(), 0644); err != nil {
+			errorLogger.Println("Failed to rewrite scrobble journal: ", err)
+		}
+		infoLogger.Printf("Flushed scrobble journal, %d of %d still pending", kept, len(pending))
+		return nil
+	}
+}
+
+// postScrobble sends a request to a scrobbling service and checks the status code.
+func postScrobble(req *http.Request) error {
+	resp, err := webClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// ========================
+// ===== ListenBrainz =====
+// ========================
+
+// listenBrainz submits listens to a ListenBrainz compatible server.
+type listenBrainz struct {
+	baseURL string
+	token   string
+}
+
+func (lb *listenBrainz) name() string { return "listenbrainz" }
+
+func (lb *listenBrainz) nowPlaying(ctx context.Context, l Listen) error {
+	return lb.submit(ctx, "playing_now", l, false)
+}
+
+func (lb *listenBrainz) scrobble(ctx context.Context, l Listen) error {
+	return lb.submit(ctx, "single", l, true)
+}
+
+// submit posts a listen to the submit-listens endpoint.
+func (lb *listenBrainz) submit(ctx context.Context, listenType string, l Listen, timestamped bool) error {
+	listen := map[string]any{
+		"track_metadata": map[string]any{
+			"artist_name":  l.Artist(),
+			"track_name":   l.Name,
+			"release_name": l.Album,
+			"additional_info": map[string]any{
+				"duration_ms":       l.DurationMs,
+				"media_player":      "JukeTUI",
+				"submission_client": "JukeTUI",
+				"music_service":     "spotify.com",
+			},
+		},
+	}
+	if timestamped {
+		listen["listened_at"] = l.StartedAt.Unix()
+	}
+	body, err := json.Marshal(map[string]any{
+		"listen_type": listenType,
+		"payload":     []any{listen},
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, lb.baseURL+"/1/submit-listens", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Token "+lb.token)
+	req.Header.Set("Content-Type", "application/json")
+	return postScrobble(req)
+}
+
+// ===================
+// ===== Last.fm =====
+// ===================
+
+// lastFM submits listens to the Last.fm (or a compatible) scrobbling API.
+type lastFM struct {
+	baseURL    string
+	apiKey     string
+	secret     string
+	sessionKey string
+}
+
+func (lf *lastFM) name() string { return "lastfm" }
+
+func (lf *lastFM) nowPlaying(ctx context.Context, l Listen) error {
+	return lf.call(ctx, "track.updateNowPlaying", l, false)
+}
+
+func (lf *lastFM) scrobble(ctx context.Context, l Listen) error {
+	return lf.call(ctx, "track.scrobble", l, true)
+}
+
+// call makes a signed Last.fm API call for a listen.
+func (lf *lastFM) call(ctx context.Context, method string, l Listen, timestamped bool) error {
+	params := map[string]string{
+		"method":   method,
+		"api_key":  lf.apiKey,
+		"sk":       lf.sessionKey,
+		"artist":   l.Artist(),
+		"track":    l.Name,
+		"album":    l.Album,
+		"duration": strconv.Itoa(l.DurationMs / 1000),
+	}
+	if len(l.Artists) > 0 {
+		params["artist"] = l.Artists[0] // Last.fm expects the primary artist only
+	}
+	if timestamped {
+		params["timestamp"] = strconv.FormatInt(l.StartedAt.Unix(), 10)
+	}
+
+	form := url.Values{}
+	for key, value := range params {
+		form.Set(key, value)
+	}
+	form.Set("api_sig", lastFMSignature(params, lf.secret))
+	form.Set("format", "json")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, lf.baseURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return postScrobble(req)
+}
+
+// lastFMSignature signs API parameters: md5 of the sorted key/value pairs followed by the shared secret.
+func lastFMSignature(params map[string]string, secret string) string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var sig strings.Builder
+	for _, key := range keys {
+		sig.WriteString(key + params[key])
+	}
+	sig.WriteString(secret)
+	sum := md5.Sum([]byte(sig.String()))
+	return hex.EncodeToString(sum[:])
+}
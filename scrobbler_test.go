@@ -0,0 +1,60 @@
+package main
+
+import "testing"
+
+func TestShouldScrobble(t *testing.T) {
+	const tenMinutes = 10 * 60 * 1000
+	tests := []struct {
+		name   string
+		listen Listen
+		want   bool
+	}{
+		{"half played", Listen{URI: "spotify:track:a", DurationMs: 200000, PlayedMs: 100000}, true},
+		{"just under half", Listen{URI: "spotify:track:a", DurationMs: 200000, PlayedMs: 99999}, false},
+		{"long track after four minutes", Listen{URI: "spotify:track:a", DurationMs: tenMinutes, PlayedMs: SCROBBLE_MAX_WAIT_MS}, true},
+		{"long track just under four minutes", Listen{URI: "spotify:track:a", DurationMs: tenMinutes, PlayedMs: SCROBBLE_MAX_WAIT_MS - 1}, false},
+		{"exactly the minimum length", Listen{URI: "spotify:track:a", DurationMs: SCROBBLE_MIN_MS, PlayedMs: SCROBBLE_MIN_MS}, false},
+		{"just over the minimum length", Listen{URI: "spotify:track:a", DurationMs: SCROBBLE_MIN_MS + 2, PlayedMs: SCROBBLE_MIN_MS/2 + 1}, true},
+		{"skipped after half still counts", Listen{URI: "spotify:track:a", DurationMs: 200000, PlayedMs: 150000, Skipped: true}, true},
+		{"podcast episode", Listen{URI: "spotify:episode:a", DurationMs: tenMinutes, PlayedMs: tenMinutes}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shouldScrobble(tt.listen); got != tt.want {
+				t.Errorf("shouldScrobble(%+v) = %v, want %v", tt.listen, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLastFMSignature(t *testing.T) {
+	tests := []struct {
+		name   string
+		params map[string]string
+		secret string
+		want   string
+	}{
+		{
+			name:   "auth example from the Last.fm docs",
+			params: map[string]string{"api_key": "xxxxxxxxxx", "method": "auth.getSession", "token": "yyyyyy"},
+			secret: "ilovecher",
+			want:   "b87d61da3cda91a8b6746c4aef55d6f8",
+		},
+		{
+			name: "scrobble",
+			params: map[string]string{
+				"method": "track.scrobble", "api_key": "key", "sk": "session", "artist": "Radiohead",
+				"track": "Airbag", "album": "OK Computer", "duration": "287", "timestamp": "1714564800",
+			},
+			secret: "secret",
+			want:   "3b5c7b73b0bf5e2bfc9bd74c6d2c1675",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lastFMSignature(tt.params, tt.secret); got != tt.want {
+				t.Errorf("signature %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+// ============================================================
+// ===== settings.go | Preferences changed inside the app =====
+// ============================================================
+
+const SETTINGS_FILE = "settings.json"
+
+// settingsFile is the on-disk layout of the settings.
+type settingsFile struct {
+	Sort map[string]string `json:"sort,omitempty"` // Sort mode of each library
+}
+
+// Settings are the preferences changed from inside the app, as opposed to .env. They live in their own file
+// in the data directory, so throwing away the library cache never loses them.
+type Settings struct {
+	path string
+	mu   sync.Mutex
+	data settingsFile
+}
+
+// openSettings loads the settings at path. A missing file starts with the defaults.
+// An unreadable one is logged and also starts with the defaults, rather than keeping JukeTUI from starting.
+func openSettings(path string) *Settings {
+	settings := &Settings{path: path}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			errorLogger.Println("Failed to read settings: ", err)
+		}
+		return settings
+	}
+	if err := json.Unmarshal(data, &settings.data); err != nil {
+		errorLogger.Println("Ignoring unreadable settings: ", err)
+		settings.data = settingsFile{}
+	}
+	return settings
+}
+
+// loadSettings opens the settings file in the data directory.
+// Sort modes used to be kept in the library cache, so those are carried over the first time.
+//
+// Parameters:
+// - legacySort: Sort modes found in the library cache, or nil.
+//
+// Returns:
+// - The settings.
+func loadSettings(legacySort map[string]string) *Settings {
+	settings := openSettings(dataPath(SETTINGS_FILE))
+	if len(legacySort) > 0 && settings.data.Sort == nil {
+		settings.mu.Lock()
+		settings.data.Sort = legacySort
+		if err := settings.save(); err != nil {
+			errorLogger.Println("Failed to save sort modes from the library cache: ", err)
+		}
+		settings.mu.Unlock()
+	}
+	return settings
+}
+
+// SortMode gets the sort mode of a library.
+func (s *Settings) SortMode(listDetail string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if mode, ok := s.data.Sort[listDetail]; ok {
+		return mode
+	}
+	return SORT_ADDED
+}
+
+// SetSortMode changes the sort mode of a library and saves it.
+func (s *Settings) SetSortMode(listDetail, mode string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.data.Sort == nil {
+		s.data.Sort = map[string]string{}
+	}
+	s.data.Sort[listDetail] = mode
+	return s.save()
+}
+
+// save writes the settings to disk. Callers hold the lock.
+func (s *Settings) save() error {
+	data, err := json.MarshalIndent(s.data, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, data)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSettingsSortMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), SETTINGS_FILE)
+	settings := openSettings(path)
+	if mode := settings.SortMode("album"); mode != SORT_ADDED {
+		t.Errorf("default sort %q, want %q", mode, SORT_ADDED)
+	}
+	if err := settings.SetSortMode("album", SORT_NAME); err != nil {
+		t.Fatal(err)
+	}
+	reopened := openSettings(path)
+	if reopened.SortMode("album") != SORT_NAME || reopened.SortMode("playlist") != SORT_ADDED {
+		t.Errorf("reopened sorts album %q, playlist %q", reopened.SortMode("album"), reopened.SortMode("playlist"))
+	}
+}
+
+func TestSettingsSurviveLibraryCache(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	cachePath := dataPath(LIBRARY_CACHE)
+	legacy := `{"version":1,"synced_at":"2024-05-01T10:00:00Z","albums":[],"playlists":[],"shows":[],"sort":{"album":"release"}}`
+	if err := os.WriteFile(cachePath, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if mode := loadSettings(openLibraryCache(cachePath).legacySortModes()).SortMode("album"); mode != SORT_RELEASE {
+		t.Fatalf("carried over %q, want %q", mode, SORT_RELEASE)
+	}
+
+	if err := os.WriteFile(cachePath, []byte("not json"), 0644); err != nil { // A corrupt cache is thrown away
+		t.Fatal(err)
+	}
+	if mode := loadSettings(openLibraryCache(cachePath).legacySortModes()).SortMode("album"); mode != SORT_RELEASE {
+		t.Errorf("sort after discarding the cache %q, want %q", mode, SORT_RELEASE)
+	}
+}
+
+func TestUnreadableSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), SETTINGS_FILE)
+	if err := os.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if mode := openSettings(path).SortMode("album"); mode != SORT_ADDED {
+		t.Errorf("sort %q from an unreadable file, want the default", mode)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ======================================================
+// ===== show.go | Podcast shows and their episodes =====
+// ======================================================
+
+// ShowPage is the episode list of a show, shown in place of the library.
+// Like ArtistPage, it is replaced rather than changed.
+type ShowPage struct {
+	Show     LibraryItem // The show row it was opened from
+	Episodes []SpotifyEpisode
+	pager    Paginator
+}
+
+// showEpisodesMsg is a page of a show's episodes, newest first.
+type showEpisodesMsg struct {
+	showURI  string
+	episodes []SpotifyEpisode
+	total    int
+	err      error
+}
+
+// showEpisodesCmd returns a command that fetches a page of episodes, with the user's resume points.
+func showEpisodesCmd(ctx context.Context, token, showURI string, pager Paginator) tea.Cmd {
+	return func() tea.Msg {
+		episodes, err := handleGenericFetch[SpotifyPage[SpotifyEpisode]](ctx, "/shows/"+uriID(showURI)+"/episodes", token, map[string]string{
+			"limit":  fmt.Sprintf("%d", min(pager.PageSize(), 50)),
+			"offset": fmt.Sprintf("%d", pager.Offset()),
+		}, nil)
+		return showEpisodesMsg{showURI: showURI, episodes: episodes.Items, total: episodes.Total, err: err}
+	}
+}
+
+// handlePlayEpisode plays an episode within its show, picking up where the user left off.
+//
+// Parameters:
+// - ctx: Cancels the request.
+// - token: Spotify access token.
+// - deviceID: The device to play on.
+// - showURI: The show the episode belongs to.
+// - episode: The episode to play.
+//
+// Returns:
+// - An error if playback couldn't start.
+func handlePlayEpisode(ctx context.Context, token, deviceID, showURI string, episode SpotifyEpisode) error {
+	body := map[string]any{"context_uri": showURI, "offset": map[string]string{"uri": episode.URI}}
+	if !episode.ResumePoint.FullyPlayed && episode.ResumePoint.ResumePositionMs > 0 {
+		body["position_ms"] = episode.ResumePoint.ResumePositionMs
+	}
+	_, err := handleGenericPut(ctx, "/me/player/play", token, map[string]string{"device_id": deviceID}, body)
+	return err
+}
+
+// episodeStatus describes how far the user got into an episode: played, part way, or not started.
+func episodeStatus(episode SpotifyEpisode) string {
+	switch {
+	case episode.ResumePoint.FullyPlayed:
+		return "✓ Played"
+	case episode.ResumePoint.ResumePositionMs > 0:
+		return msToMinSec(episode.DurationMs-episode.ResumePoint.ResumePositionMs) + " left"
+	}
+	return episode.ReleaseDate + " " + msToMinSec(episode.DurationMs)
+}
+
+// rows gets the library rows of a show page, one per episode.
+func (p *ShowPage) rows(favorites *FavoritesStore) []LibraryItem {
+	rows := []LibraryItem{}
+	for _, episode := range p.Episodes {
+		rows = append(rows, LibraryItem{name: displayEpisode(episode).Title, artist: episodeStatus(episode), uri: episode.URI, favorite: favorites.Contains(episode.URI)})
+	}
+	return rows
+}
+
+// header describes the show and which page of episodes is shown.
+func (p *ShowPage) header() string {
+	return fmt.Sprintf("%s  %s  Page %d of %d", p.Show.name, p.Show.artist, p.pager.Page()+1, p.pager.Pages())
+}
+
+// openShow opens the episode list of a show row.
+func (m Model) openShow(show LibraryItem) (Model, tea.Cmd) {
+	page := &ShowPage{Show: show, pager: Paginator{}.Resize(m.height-LIBRARY_SPACING, 0)}
+	m.show, m.libraryList, m.cursor, m.loading = page, []LibraryItem{}, 0, true
+	return m.fetchLibrary(func(ctx context.Context) tea.Cmd {
+		return showEpisodesCmd(ctx, m.token, show.uri, page.pager)
+	})
+}
+
+// closeShow goes back from a show page to the library.
+func (m Model) closeShow() (Model, tea.Cmd) {
+	m.show, m.libraryList, m.cursor = nil, nil, 0
+	return m.reloadLibrary()
+}
+
+// pageShow moves the open show page to another page of episodes.
+func (m Model) pageShow(pager Paginator) (Model, tea.Cmd) {
+	page := *m.show
+	page.pager = pager
+	m.show = &page
+	m.loading = true
+	return m.fetchLibrary(func(ctx context.Context) tea.Cmd {
+		return showEpisodesCmd(ctx, m.token, page.Show.uri, pager)
+	})
+}
+
+// selectEpisode plays the episode under the cursor, resuming it if it was started.
+func (m Model) selectEpisode() (Model, tea.Cmd) {
+	if len(m.show.Episodes) == 0 || !m.state.IsPlaying {
+		return m, nil
+	}
+	showURI, episode := m.show.Show.uri, m.show.Episodes[m.cursor]
+	return m.startPlay("play", func(ctx context.Context, token, deviceID string) error {
+		return handlePlayEpisode(ctx, token, deviceID, showURI, episode)
+	})
+}
@@ -31,7 +31,11 @@ var SPOTIFY_PERMS = []string{
 	"user-read-playback-state",
 	"user-modify-playback-state",
 	"playlist-read-private",
+	"playlist-modify-private",
 	"user-library-read",
+	"user-follow-read",
+	"user-follow-modify",
+	"user-read-playback-position",
 }
 
 // Opens the login page on the users primary browser, prompting for login.
@@ -83,6 +87,18 @@ func GetCodeFromCallback() string {
 	return code
 }
 
+// Runs the whole browser login flow and returns the token, exiting if it fails.
+func login(clientID, clientSecret string) SpotifyTokenResponse {
+	fmt.Println("Opening login page...")
+	OpenLoginPage(clientID)
+	code := GetCodeFromCallback()
+	token, err := GetSpotifyToken(context.Background(), clientID, clientSecret, code)
+	if err != nil {
+		log.Fatalf("Failed to get token: %v", err)
+	}
+	return token
+}
+
 // Given the client ID, client secret, and authorization code, returns the Spotify token response.
 func GetSpotifyToken(ctx context.Context, clientID, clientSecret, code string) (SpotifyTokenResponse, error) {
 	data := url.Values{}
@@ -97,8 +113,7 @@ func GetSpotifyToken(ctx context.Context, clientID, clientSecret, code string) (
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	req.SetBasicAuth(clientID, clientSecret)
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := spotifyClient.Do(req)
 	if err != nil {
 		return SpotifyTokenResponse{}, err
 	}
@@ -118,28 +133,31 @@ func GetSpotifyToken(ctx context.Context, clientID, clientSecret, code string) (
 }
 
 // refreshSpotifyTokenCmd returns a command that refreshes the Spotify token.
-func refreshSpotifyTokenCmd(refreshToken, clientID, clientSecret string) tea.Cmd {
+func refreshSpotifyTokenCmd(ctx context.Context, refreshToken, clientID, clientSecret string) tea.Cmd {
 	return func() tea.Msg {
-		newToken, err := RefreshSpotifyToken(refreshToken, clientID, clientSecret)
+		newToken, err := RefreshSpotifyToken(ctx, refreshToken, clientID, clientSecret)
 		if err != nil {
-			return fmt.Errorf("failed to refresh token: %v", err)
+			return tokenFailedMsg{err: err}
 		}
 		return newToken
 	}
 }
 
 // RefreshSpotifyToken refreshes the Spotify token using the refresh token.
-func RefreshSpotifyToken(refreshToken, clientID, clientSecret string) (SpotifyTokenResponse, error) {
+func RefreshSpotifyToken(ctx context.Context, refreshToken, clientID, clientSecret string) (SpotifyTokenResponse, error) {
 	reqBody := url.Values{
 		"grant_type":    {"refresh_token"},
 		"refresh_token": {refreshToken},
 	}.Encode()
 
-	req, _ := http.NewRequest("POST", SPOTIFY_TOKEN_URL, strings.NewReader(reqBody))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, SPOTIFY_TOKEN_URL, strings.NewReader(reqBody))
+	if err != nil {
+		return SpotifyTokenResponse{}, err
+	}
 	req.SetBasicAuth(clientID, clientSecret)
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := spotifyClient.Do(req)
 	if err != nil {
 		return SpotifyTokenResponse{}, err
 	}
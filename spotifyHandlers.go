@@ -1,8 +1,11 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"math"
+	"net/http"
+	"strconv"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 )
@@ -14,29 +17,32 @@ import (
 // handleGenericFetch handles and error checks a generic fetch
 //
 // Parameters:
+// - ctx: Cancels the request.
 // - endpoint: The endpoint to fetch data from.
 // - accessToken: Spotify access token.
 // - queryParams: Query parameters as a map of strings.
-// - bodyArgs: Body arguments as a map of strings.
+// - bodyArgs: Body arguments, encoded as a JSON object.
 //
 // Returns:
-// - The data fetched from the endpoint.
+// - The data fetched from the endpoint, empty if the fetch failed.
+// - An error if the fetch failed, an *APIError if Spotify answered with an error status.
 //
 // Type	parameters:
 // - T: The type of data expected to be fetched from the endpoint.
-func handleGenericFetch[T any](endpoint, accessToken string, queryParams, bodyArgs map[string]string) T {
-	data, err := genericFetch[T](endpoint, accessToken, queryParams, bodyArgs)
+func handleGenericFetch[T any](ctx context.Context, endpoint, accessToken string, queryParams map[string]string, bodyArgs map[string]any) (T, error) {
+	data, err := genericFetch[T](ctx, endpoint, accessToken, queryParams, bodyArgs)
 	if err != nil {
 		errorLogger.Printf("Failed to fetch data from %s: %v", endpoint, err)
 		var empty T
-		return empty
+		return empty, err
 	}
-	return data
+	return data, nil
 }
 
 // handleGenericPut handles and error checks a generic PUT request.
 //
 // Parameters:
+// - ctx: Cancels the request.
 // - endpoint: The endpoint to send data to
 // - accessToken: Spotify access token
 // - queryParams: Query parameters
@@ -45,8 +51,8 @@ func handleGenericFetch[T any](endpoint, accessToken string, queryParams, bodyAr
 // Returns:
 // - statusCode: The status code of the request
 // - err: The error message
-func handleGenericPut(endpoint, accessToken string, queryParams, bodyArgs map[string]string) (int, error) {
-	statusCode, err := genericPut(endpoint, accessToken, queryParams, bodyArgs)
+func handleGenericPut(ctx context.Context, endpoint, accessToken string, queryParams map[string]string, bodyArgs map[string]any) (int, error) {
+	statusCode, err := genericPut(ctx, endpoint, accessToken, queryParams, bodyArgs)
 	if err != nil {
 		errorLogger.Printf("Failed to put data to %s: %v", endpoint, err)
 		return statusCode, err
@@ -57,16 +63,17 @@ func handleGenericPut(endpoint, accessToken string, queryParams, bodyArgs map[st
 // handleGenericPost handles and error checks a generic POST request.
 //
 // Parameters:
+// - ctx: Cancels the request.
 // - endpoint: The endpoint to post data to.
 // - accessToken: Spotify access token.
 // - queryParams: Query parameters as a map of strings.
-// - bodyArgs: Body arguments as a map of strings.
+// - bodyArgs: Body arguments, encoded as a JSON object.
 //
 // Returns:
 // - The status code of the request.
 // - An error if the request failed.
-func handleGenericPost(endpoint, accessToken string, queryParams, bodyArgs map[string]string) (int, error) {
-	statusCode, err := genericPost(endpoint, accessToken, queryParams, bodyArgs)
+func handleGenericPost(ctx context.Context, endpoint, accessToken string, queryParams map[string]string, bodyArgs map[string]any) (int, error) {
+	statusCode, err := genericPost(ctx, endpoint, accessToken, queryParams, bodyArgs)
 	if err != nil {
 		errorLogger.Printf("Failed to post data to %s: %v", endpoint, err)
 		return statusCode, err
@@ -74,105 +81,189 @@ func handleGenericPost(endpoint, accessToken string, queryParams, bodyArgs map[s
 	return statusCode, nil
 }
 
+// handleGenericCreate handles and error checks a POST request that creates something.
+//
+// Parameters:
+// - ctx: Cancels the request.
+// - endpoint: The endpoint to post to.
+// - accessToken: Spotify access token.
+// - queryParams: Query parameters as a map of strings.
+// - bodyArgs: Body arguments, encoded as a JSON object.
+//
+// Returns:
+// - The created object.
+// - An error if the request failed.
+//
+// Type parameters:
+// - T: The type of the created object.
+func handleGenericCreate[T any](ctx context.Context, endpoint, accessToken string, queryParams map[string]string, bodyArgs map[string]any) (T, error) {
+	data, err := genericCreate[T](ctx, endpoint, accessToken, queryParams, bodyArgs)
+	if err != nil {
+		errorLogger.Printf("Failed to create at %s: %v", endpoint, err)
+	}
+	return data, err
+}
+
+// handleFetchAll fetches every page of a paginated endpoint, 50 items at a time.
+//
+// Parameters:
+// - ctx: Cancels the request.
+// - endpoint: The endpoint to fetch, e.g. /me/albums.
+// - token: Spotify access token.
+//
+// Returns:
+// - Every item, in API order.
+// - An error if any page failed, in which case there are no items.
+//
+// Type parameters:
+// - T: The type of one item in the page.
+func handleFetchAll[T any](ctx context.Context, endpoint, token string) ([]T, error) {
+	items, err := fetchAll[T](ctx, endpoint, token)
+	if err != nil {
+		errorLogger.Printf("Error fetching all of %s: %v", endpoint, err)
+	}
+	return items, err
+}
+
+// handlePlayURI starts playing any Spotify URI the way its kind needs.
+// Albums, playlists, artists and shows play as a context, tracks and episodes play on their own.
+// Shuffle is turned off for albums and on for playlists; other kinds keep the current setting.
+//
+// Parameters:
+// - ctx: Cancels the request.
+// - token: Spotify access token.
+// - deviceID: The device to play on.
+// - uri: The URI to play.
+//
+// Returns:
+// - An error if playback couldn't start. A failure to set shuffle is only logged.
+func handlePlayURI(ctx context.Context, token, deviceID, uri string) error {
+	switch uriKind(uri) {
+	case "album":
+		handleGenericPut(ctx, "/me/player/shuffle", token, map[string]string{"state": "false"}, nil)
+	case "playlist":
+		handleGenericPut(ctx, "/me/player/shuffle", token, map[string]string{"state": "true"}, nil)
+	}
+	body := map[string]any{"context_uri": uri}
+	if kind := uriKind(uri); kind == "track" || kind == "episode" {
+		body = map[string]any{"uris": []string{uri}}
+	}
+	_, err := handleGenericPut(ctx, "/me/player/play", token, map[string]string{"device_id": deviceID}, body)
+	return err
+}
+
+// handlePlayInContext starts playing a context, such as an album, from one of its tracks.
+//
+// Parameters:
+// - ctx: Cancels the request.
+// - token: Spotify access token.
+// - deviceID: The device to play on.
+// - contextURI: The album or playlist to play.
+// - trackURI: The track to start from.
+//
+// Returns:
+// - An error if playback couldn't start.
+func handlePlayInContext(ctx context.Context, token, deviceID, contextURI, trackURI string) error {
+	body := map[string]any{"context_uri": contextURI, "offset": map[string]string{"uri": trackURI}}
+	_, err := handleGenericPut(ctx, "/me/player/play", token, map[string]string{"device_id": deviceID}, body)
+	return err
+}
+
 // handleFetchPlayback handles fetching and error checking of the playback state.
 //
 // Parameters:
+// - ctx: Cancels the request.
 // - token: Spotify access token.
 //
 // Returns:
-// - The playback state.
-func handleFetchPlayback(token string) tea.Cmd {
+// - The playback state, playbackIdleMsg if no device is playing anything, or playbackFailedMsg.
+func handleFetchPlayback(ctx context.Context, token string) tea.Cmd {
 	return func() tea.Msg {
-		state := handleGenericFetch[PlaybackState]("/me/player", token, nil, nil)
+		ctx, cancel := context.WithTimeout(ctx, POLL_TIMEOUT)
+		defer cancel()
+		start := time.Now()
+		state, statusCode, err := genericRequest[PlaybackState](ctx, http.MethodGet, "/me/player", token, map[string]string{"additional_types": "episode"}, nil)
+		if err != nil {
+			errorLogger.Printf("Failed to fetch playback state: %v", err)
+			return playbackFailedMsg{err: err}
+		}
+		if statusCode == http.StatusNoContent {
+			return playbackIdleMsg{}
+		}
+		state.sampledAt = start.Add(time.Since(start) / 2) // Halfway through the round trip is our best guess
 		return state
 	}
 }
 
-// handleFetchLibrary fetches the user's library from the Spotify API.
+// handleFetchLibrary gets a page of the user's library from the library cache.
 //
 // Parameters:
-// - token: Spotify access token.
-// - listDetail: The type of library to fetch (album or playlist).
-// - height: The number of items to fetch.
+// - cache: The library cache, kept in sync in the background.
+// - history: Listening history, for sorting by recently played.
+// - favorites: Favorites, left out of the page since they are listed separately.
+// - listDetail: The type of library to fetch (album, playlist, show or favorites).
+// - sortMode: The library's sort mode, from the settings.
+// - filter: Tag filter and search. A search ranks the whole library, favorites included.
+// - pager: Which page to get.
 //
 // Returns:
-// - The fetched library.
-func handleFetchLibrary(favorites []LibraryFavorite, token string, listDetail string, height, offset int) tea.Cmd {
+// - The library page. The Favorites view is local, so it only gets told to refresh.
+func handleFetchLibrary(cache *LibraryCache, history *History, favorites []LibraryFavorite, listDetail, sortMode string, filter libraryFilter, pager Paginator) tea.Cmd {
 	return func() tea.Msg {
-		height = int(math.Min(float64(height), 50))
-		if listDetail == "album" {
-			albums := handleGenericFetch[SpotifyAlbum]("/me/albums", token, map[string]string{"limit": fmt.Sprintf("%d", height), "offset": fmt.Sprintf("%d", offset)}, nil)
-			removed := 0
-
-			for _, item := range albums.Items {
-				for _, favorite := range favorites {
-					if item.Album.URI == favorite.URI {
-						removed++
-					}
-				}
-			}
-			albums = handleGenericFetch[SpotifyAlbum]("/me/albums", token, map[string]string{"limit": fmt.Sprintf("%d", height+removed), "offset": fmt.Sprintf("%d", offset)}, nil)
-			filteredItems := make([]struct{ SpotifyAlbumItem }, 0, len(albums.Items))
-			for _, item := range albums.Items {
-				isFavorite := false
-				for _, favorite := range favorites {
-					if item.Album.URI == favorite.URI {
-						isFavorite = true
-						break
-					}
-				}
-				if !isFavorite {
-					filteredItems = append(filteredItems, item)
+		if filter.search != "" {
+			var items []LibraryItem
+			for _, favorite := range favorites {
+				if (listDetail == "favorites" || uriKind(favorite.URI) == listDetail) && (filter.tag == "" || favorite.HasTag(filter.tag)) {
+					items = append(items, favoriteItem(favorite, listDetail == "favorites"))
 				}
 			}
-			albums.Items = filteredItems
-			return albums
-		} else {
-			playlist := handleGenericFetch[SpotifyPlaylist]("/me/playlists", token, map[string]string{"limit": fmt.Sprintf("%d", height), "offset": fmt.Sprintf("%d", offset)}, nil)
-			filteredItems := make([]SpotifyPlaylistItem, 0, len(playlist.Items))
-			favoriteURIs := make(map[string]struct{})
-			for _, favorite := range favorites {
-				favoriteURIs[favorite.URI] = struct{}{}
+			if listDetail != "favorites" && filter.tag == "" { // Only favorites can have tags
+				items = append(items, cache.items(listDetail, favorites)...)
 			}
-			for _, item := range playlist.Items {
-				if _, found := favoriteURIs[item.URI]; !found {
-					filteredItems = append(filteredItems, item)
-				}
+			page, total := searchLibrary(items, filter.search, pager)
+			return librarySearchMsg{listDetail: listDetail, search: filter.search, items: page, total: total}
+		}
+		if listDetail == "favorites" {
+			return favoritesPageMsg{}
+		}
+		var played map[string]time.Time
+		if sortMode == SORT_PLAYED {
+			var err error
+			if played, err = history.lastPlayed(); err != nil {
+				errorLogger.Println("Failed to read listening history: ", err)
 			}
-			playlist.Items = filteredItems
-			return playlist
 		}
+		return cache.page(listDetail, sortMode, favorites, pager, played)
 	}
 }
 
-// handleFetchPlaylist fetches a playlist from the Spotify API.
+// handleSeek moves playback of the current item to a position.
 //
 // Parameters:
+// - ctx: Cancels the request.
 // - token: Spotify access token.
-// - listDetail: The type of playlist to fetch (album or playlist).
+// - positionMs: The position to seek to, in ms.
 //
 // Returns:
-// - The fetched playlist.
-func handleGetLibraryTotal(token string, listDetail string) tea.Cmd {
-	return func() tea.Msg {
-		if listDetail == "album" {
-			albums := handleGenericFetch[SpotifyAlbum]("/me/albums", token, map[string]string{"limit": "1"}, nil)
-			return albums.Total
-		} else {
-			playlist := handleGenericFetch[SpotifyPlaylist]("/me/playlists", token, map[string]string{"limit": "1"}, nil)
-			return playlist.Total
-		}
-	}
+// - The status code and error of the request.
+func handleSeek(ctx context.Context, token string, positionMs int) (int, error) {
+	return handleGenericPut(ctx, "/me/player/seek", token, map[string]string{"position_ms": strconv.Itoa(positionMs)}, nil)
 }
 
-// handleFetchPlaylist fetches a playlist from the Spotify API.
+// handleGetQueue fetches the queue after the playing item.
 //
 // Parameters:
+// - ctx: Cancels the request.
 // - token: Spotify access token.
-
-func handleGetQueue(token string) tea.Cmd {
+//
+// Returns:
+// - A command returning the Queue, or an error if it couldn't be fetched.
+func handleGetQueue(ctx context.Context, token string) tea.Cmd {
 	return func() tea.Msg {
-		queue := handleGenericFetch[Queue]("/me/player/queue", token, nil, nil)
+		queue, err := handleGenericFetch[Queue](ctx, "/me/player/queue", token, nil, nil)
+		if err != nil {
+			return fmt.Errorf("fetching the queue: %w", err)
+		}
 		return queue
 	}
 }
@@ -2,9 +2,11 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"time"
 )
@@ -13,9 +15,40 @@ import (
 // ===== spotifyRequests.go | Interact with Spotify API =====
 // =====================================
 
+const (
+	REQUEST_TIMEOUT = 20 * time.Second // Longest any request may take, whatever its context allows
+	DIAL_TIMEOUT    = 5 * time.Second
+	IDLE_CONNS      = 8 // Kept open per host, more than the requests we ever have in flight at once
+	WEB_TIMEOUT     = 10 * time.Second
+)
+
+// spotifyClient is shared by every Spotify request, so connections are kept alive and reused
+// instead of paying for a new TLS handshake on each poll.
+var spotifyClient = &http.Client{
+	Timeout: REQUEST_TIMEOUT,
+	Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: DIAL_TIMEOUT, KeepAlive: 30 * time.Second}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          2 * IDLE_CONNS,
+		MaxIdleConnsPerHost:   IDLE_CONNS,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   DIAL_TIMEOUT,
+		ExpectContinueTimeout: 1 * time.Second,
+	},
+}
+
+// webClient makes every request that isn't to the Spotify API: cover art, lyrics and scrobbles.
+// It shares spotifyClient's transport, so it keeps connections alive the same way, with a shorter timeout.
+var webClient = &http.Client{
+	Timeout:   WEB_TIMEOUT,
+	Transport: spotifyClient.Transport,
+}
+
 // genericRequest makes an HTTP request to the Spotify API and returns the response as a struct or a response code.
 //
 // Parameters:
+// - ctx: cancels the request, e.g. when a newer one replaces it or the app quits
 // - method: the HTTP method to use (GET, POST, PUT)
 // - endpoint: the endpoint to fetch data from
 // - accessToken: the access token to authenticate the request
@@ -23,13 +56,13 @@ import (
 // - bodyArgs: the body arguments to include in the request
 //
 // Returns:
-// - T: the response data as a struct if method is GET
-// - int: the response code if method is POST or PUT
-// - error: an error if the request fails
+// - T: the response data as a struct if method is GET, or the request created something
+// - int: the response code, 0 if there was no response
+// - error: an error if the request fails. Error statuses are an *APIError
 //
 // Type Parameters:
 // - T: the type of the response data
-func genericRequest[T any](method, endpoint, accessToken string, queryParams, bodyArgs map[string]string) (T, int, error) {
+func genericRequest[T any](ctx context.Context, method, endpoint, accessToken string, queryParams map[string]string, bodyArgs map[string]any) (T, int, error) {
 	var result T
 	var resp *http.Response
 
@@ -38,40 +71,37 @@ func genericRequest[T any](method, endpoint, accessToken string, queryParams, bo
 	if bodyArgs != nil {
 		bodyJSON, err := json.Marshal(bodyArgs)
 		if err != nil {
-			return result, 500, err
+			return result, 0, err
 		}
 		body = bytes.NewReader(bodyJSON)
 	}
 
-	req, err := http.NewRequest(method, createEndpoint(endpoint, queryParams), body)
+	req, err := http.NewRequestWithContext(ctx, method, createEndpoint(endpoint, queryParams), body)
 	if err != nil {
-		return result, 500, err
+		return result, 0, err
 	}
 
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
 	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{Timeout: 20 * time.Second}
-	resp, err = client.Do(req)
+	resp, err = spotifyClient.Do(req)
 	if err != nil {
-		if resp != nil {
-			return result, resp.StatusCode, err
-		}
-		return result, 500, err
+		return result, 0, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusForbidden {
-		return result, resp.StatusCode, fmt.Errorf("missing required permissions")
+	if resp.StatusCode >= http.StatusBadRequest {
+		return result, resp.StatusCode, newAPIError(resp)
 	}
 
-	if method == http.MethodGet {
+	// 204 has no body to decode; callers that care, like the playback poll, check for it
+	if resp.StatusCode != http.StatusNoContent && (method == http.MethodGet || resp.StatusCode == http.StatusCreated) {
 		bodyBytes, err := io.ReadAll(resp.Body)
 		if err != nil {
 			return result, resp.StatusCode, err
 		}
 		if err := json.Unmarshal(bodyBytes, &result); err != nil {
-			return result, resp.StatusCode, err
+			return result, resp.StatusCode, fmt.Errorf("decoding %s %s: %w", method, endpoint, err)
 		}
 	}
 	infoLogger.Printf("Successful %s %s %d", method, endpoint, resp.StatusCode)
@@ -79,19 +109,48 @@ func genericRequest[T any](method, endpoint, accessToken string, queryParams, bo
 }
 
 // genericFetch makes a GET request to the Spotify API and returns the response as a struct.
-func genericFetch[T any](endpoint, accessToken string, queryParams, bodyArgs map[string]string) (T, error) {
-	result, _, err := genericRequest[T](http.MethodGet, endpoint, accessToken, queryParams, bodyArgs)
+func genericFetch[T any](ctx context.Context, endpoint, accessToken string, queryParams map[string]string, bodyArgs map[string]any) (T, error) {
+	result, _, err := genericRequest[T](ctx, http.MethodGet, endpoint, accessToken, queryParams, bodyArgs)
 	return result, err
 }
 
 // genericPut makes a PUT request to the Spotify API and returns the response code.
-func genericPut(endpoint, accessToken string, queryParams, bodyArgs map[string]string) (int, error) {
-	_, statusCode, err := genericRequest[struct{}](http.MethodPut, endpoint, accessToken, queryParams, bodyArgs)
+func genericPut(ctx context.Context, endpoint, accessToken string, queryParams map[string]string, bodyArgs map[string]any) (int, error) {
+	_, statusCode, err := genericRequest[struct{}](ctx, http.MethodPut, endpoint, accessToken, queryParams, bodyArgs)
 	return statusCode, err
 }
 
 // genericPost makes a POST request to the Spotify API and returns the response code.
-func genericPost(endpoint, accessToken string, queryParams, bodyArgs map[string]string) (int, error) {
-	_, statusCode, err := genericRequest[struct{}](http.MethodPost, endpoint, accessToken, queryParams, bodyArgs)
+func genericPost(ctx context.Context, endpoint, accessToken string, queryParams map[string]string, bodyArgs map[string]any) (int, error) {
+	_, statusCode, err := genericRequest[struct{}](ctx, http.MethodPost, endpoint, accessToken, queryParams, bodyArgs)
 	return statusCode, err
 }
+
+// genericDelete makes a DELETE request to the Spotify API and returns the response code.
+func genericDelete(ctx context.Context, endpoint, accessToken string, queryParams map[string]string, bodyArgs map[string]any) (int, error) {
+	_, statusCode, err := genericRequest[struct{}](ctx, http.MethodDelete, endpoint, accessToken, queryParams, bodyArgs)
+	return statusCode, err
+}
+
+// genericCreate makes a POST request that creates something, returning the created object as a struct.
+func genericCreate[T any](ctx context.Context, endpoint, accessToken string, queryParams map[string]string, bodyArgs map[string]any) (T, error) {
+	result, _, err := genericRequest[T](ctx, http.MethodPost, endpoint, accessToken, queryParams, bodyArgs)
+	return result, err
+}
+
+// fetchAll fetches every page of a paginated endpoint, stopping at the first failed page,
+// so a failed page is never mistaken for an empty one.
+func fetchAll[T any](ctx context.Context, endpoint, accessToken string) ([]T, error) {
+	const pageSize = 50
+	var items []T
+	for offset := 0; ; offset += pageSize {
+		page, err := genericFetch[SpotifyPage[T]](ctx, endpoint, accessToken, map[string]string{"limit": fmt.Sprintf("%d", pageSize), "offset": fmt.Sprintf("%d", offset)}, nil)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, page.Items...)
+		if len(page.Items) == 0 || offset+pageSize >= page.Total {
+			return items, nil
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/url"
 	"os"
+	"strings"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -37,6 +38,26 @@ func createEndpoint(endpoint string, queryParams map[string]string) string { //
 	return fmt.Sprintf("%s?%s", endpoint, query.Encode())
 }
 
+// uriKind gets the kind of item a Spotify URI points at, e.g. "album" for spotify:album:abc.
+//
+// Parameters:
+// - uri: the Spotify URI
+//
+// Returns:
+// - string: the kind, or "" if the URI isn't one
+func uriKind(uri string) string {
+	parts := strings.Split(uri, ":")
+	if len(parts) < 3 || parts[0] != "spotify" {
+		return ""
+	}
+	return parts[len(parts)-2] // Old playlist URIs look like spotify:user:name:playlist:id
+}
+
+// uriID gets the ID at the end of a Spotify URI, e.g. "abc" for spotify:album:abc.
+func uriID(uri string) string {
+	return uri[strings.LastIndex(uri, ":")+1:]
+}
+
 // Check if the token is expired
 //
 // Parameters:
@@ -57,7 +78,7 @@ func (s *SpotifyTokenResponse) IsExpired() bool {
 // - tea.Cmd: a command to refresh the token if it has expired
 func CheckTokenExpiryCmd(m Model) tea.Cmd {
 	if time.Now().After(m.tokenExpiresAt) {
-		return refreshSpotifyTokenCmd(m.refreshToken, os.Getenv("SPOTIFY_ID"), os.Getenv("SPOTIFY_SECRET"))
+		return refreshSpotifyTokenCmd(m.ctx, m.refreshToken, os.Getenv("SPOTIFY_ID"), os.Getenv("SPOTIFY_SECRET"))
 	}
 	return nil
 }
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/rivo/uniseg"
+)
+
+// ============================================================
+// ===== textLayout.go | Fitting text into terminal cells =====
+// ============================================================
+
+const ELLIPSIS = "…"
+const MARQUEE_GAP = "   "  // Space between the end of a scrolling title and its start coming round again
+const COLUMN_GAP = "  "    // Space between aligned columns
+const MAX_COLUMN_SHARE = 3 // A sized column gets at most a third of the row
+
+// textWidth gets how many terminal cells a string takes. Wide characters (CJK, most emoji) take two,
+// and styling takes none.
+func textWidth(str string) int {
+	return lipgloss.Width(str)
+}
+
+// truncate cuts a string to fit a width, ending it with an ellipsis if anything was cut.
+// It never splits a character, even one made of several runes like a flag or a family emoji.
+func truncate(str string, width int) string {
+	if textWidth(str) <= width {
+		return str
+	}
+	if width <= 0 {
+		return ""
+	}
+	var out strings.Builder
+	used := 0
+	graphemes := uniseg.NewGraphemes(str)
+	for graphemes.Next() {
+		if used+graphemes.Width() > width-textWidth(ELLIPSIS) {
+			break
+		}
+		out.WriteString(graphemes.Str())
+		used += graphemes.Width()
+	}
+	return out.String() + ELLIPSIS
+}
+
+// pad fills a string, styled or not, with spaces up to a width. Strings already that wide are left alone.
+func pad(str string, width int) string {
+	return str + strings.Repeat(" ", max(0, width-textWidth(str)))
+}
+
+// padLeft is pad, with the spaces in front, for right aligned columns like durations.
+func padLeft(str string, width int) string {
+	return strings.Repeat(" ", max(0, width-textWidth(str))) + str
+}
+
+// marquee shows a window of a string that is too long for its width, scrolling one cell per step.
+// Strings that fit are returned as they are.
+//
+// Parameters:
+// - str: The text to show.
+// - width: Cells available.
+// - step: How far it has scrolled, counting up forever. It wraps around.
+//
+// Returns:
+// - Exactly width cells of text, or str if it fits.
+func marquee(str string, width, step int) string {
+	if textWidth(str) <= width || width <= 0 {
+		return str
+	}
+	var clusters []string
+	graphemes := uniseg.NewGraphemes(str + MARQUEE_GAP)
+	for graphemes.Next() {
+		clusters = append(clusters, graphemes.Str())
+	}
+
+	var out strings.Builder
+	used := 0
+	for i := step % len(clusters); ; i = (i + 1) % len(clusters) {
+		w := textWidth(clusters[i])
+		if used+w > width {
+			break
+		}
+		out.WriteString(clusters[i])
+		used += w
+	}
+	return pad(out.String(), width) // A wide character that didn't fit leaves a cell over
+}
+
+// columnWidth gets the width of a column sized to its widest cell, but no more than a share of the row.
+func columnWidth(cells []string, rowWidth int) int {
+	widest := 0
+	for _, cell := range cells {
+		widest = max(widest, textWidth(cell))
+	}
+	return min(widest, rowWidth/MAX_COLUMN_SHARE)
+}
+
+// visibleMatches drops the matched positions that truncation cut off, ellipsis included.
+// Positions count grapheme clusters, the way truncate cuts.
+func visibleMatches(positions []int, shown, full string) []int {
+	if shown == full {
+		return positions
+	}
+	visible := uniseg.GraphemeClusterCount(shown) - uniseg.GraphemeClusterCount(ELLIPSIS)
+	return positions[:sort.SearchInts(positions, visible)]
+}
+
+// graphemeIndexes turns rune indexes into a string into the indexes of the characters (grapheme clusters)
+// holding them, so a flag, a ZWJ emoji or a letter with combining marks counts once. Sorted input stays sorted.
+func graphemeIndexes(str string, runes []int) []int {
+	if len(runes) == 0 {
+		return nil
+	}
+	indexes := make([]int, 0, len(runes))
+	graphemes := uniseg.NewGraphemes(str)
+	end, next := 0, 0 // Rune just past the current cluster, and the next rune index to place
+	for cluster := 0; graphemes.Next() && next < len(runes); cluster++ {
+		end += len(graphemes.Runes())
+		for next < len(runes) && runes[next] < end {
+			if len(indexes) == 0 || indexes[len(indexes)-1] != cluster {
+				indexes = append(indexes, cluster)
+			}
+			next++
+		}
+	}
+	return indexes
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	flags     = "\U0001F1EF\U0001F1F5\U0001F1EF\U0001F1F5 Japan" // Two flags of two runes, two cells each
+	family    = "\U0001F468\u200d\U0001F469\u200d\U0001F467abc"  // One ZWJ emoji of five runes, two cells
+	combining = "e\u0301e\u0301e\u0301e\u0301e\u0301"            // Five é written with combining accents
+	cjk       = "東京タワー"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name  string
+		str   string
+		width int
+		want  string
+	}{
+		{name: "fits", str: "abc", width: 3, want: "abc"},
+		{name: "cut", str: "abcdef", width: 4, want: "abc…"},
+		{name: "only the ellipsis fits", str: "abc", width: 1, want: "…"},
+		{name: "no room", str: "abc", width: 0, want: ""},
+		{name: "wide characters", str: cjk, width: 5, want: "東京…"},
+		{name: "wide character that doesn't fit", str: cjk, width: 4, want: "東…"},
+		{name: "flags stay whole", str: flags, width: 4, want: "\U0001F1EF\U0001F1F5…"},
+		{name: "ZWJ emoji stays whole", str: family, width: 4, want: family[:len(family)-2] + "…"},
+		{name: "combining marks stay with their letter", str: combining, width: 3, want: "éé…"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncate(tt.str, tt.width)
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if textWidth(got) > max(tt.width, 0) {
+				t.Errorf("%q takes %d cells, more than %d", got, textWidth(got), tt.width)
+			}
+		})
+	}
+}
+
+func TestMarquee(t *testing.T) {
+	tests := []struct {
+		name  string
+		str   string
+		width int
+		step  int
+		want  string
+	}{
+		{name: "fits", str: "abc", width: 5, step: 3, want: "abc"},
+		{name: "start", str: "abcdef", width: 4, step: 0, want: "abcd"},
+		{name: "scrolled", str: "abcdef", width: 4, step: 2, want: "cdef"},
+		{name: "into the gap", str: "abcdef", width: 4, step: 5, want: "f   "},
+		{name: "wraps around", str: "abcdef", width: 4, step: 9, want: "abcd"},
+		{name: "wide character left over", str: cjk, width: 5, step: 0, want: "東京 "},
+		{name: "flags stay whole", str: flags, width: 3, step: 1, want: "\U0001F1EF\U0001F1F5 "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := marquee(tt.str, tt.width, tt.step)
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPad(t *testing.T) {
+	if got := pad(cjk[:3], 4); got != "東  " {
+		t.Errorf("pad got %q", got)
+	}
+	if got := padLeft("3:05", 6); got != "  3:05" {
+		t.Errorf("padLeft got %q", got)
+	}
+	if got := pad(cjk, 4); got != cjk {
+		t.Errorf("pad of a wider string got %q, want it untouched", got)
+	}
+	if got := pad(lipgloss.NewStyle().Bold(true).Render("ab"), 3); textWidth(got) != 3 {
+		t.Errorf("padded styled text takes %d cells, want 3", textWidth(got))
+	}
+}
+
+func TestColumnWidth(t *testing.T) {
+	tests := []struct {
+		name     string
+		cells    []string
+		rowWidth int
+		want     int
+	}{
+		{name: "widest cell", cells: []string{"ab", "abcd"}, rowWidth: 30, want: 4},
+		{name: "wide characters count double", cells: []string{"ab", cjk}, rowWidth: 30, want: 10},
+		{name: "capped to a share of the row", cells: []string{cjk}, rowWidth: 9, want: 3},
+		{name: "empty", cells: nil, rowWidth: 30, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := columnWidth(tt.cells, tt.rowWidth); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVisibleMatches(t *testing.T) {
+	tests := []struct {
+		name      string
+		full      string
+		width     int
+		positions []int
+		want      []int
+	}{
+		{name: "not truncated", full: "abc", width: 5, positions: []int{0, 2}, want: []int{0, 2}},
+		{name: "cut off", full: "abcdef", width: 4, positions: []int{0, 2, 3, 5}, want: []int{0, 2}},
+		{name: "after a flag", full: flags, width: 5, positions: []int{0, 1, 3}, want: []int{0, 1}},
+		{name: "after a ZWJ emoji", full: family, width: 4, positions: []int{1, 2}, want: []int{1}},
+		{name: "after combining marks", full: combining, width: 3, positions: []int{1, 2}, want: []int{1}},
+		{name: "wide characters", full: cjk, width: 5, positions: []int{1, 2}, want: []int{1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := visibleMatches(tt.positions, truncate(tt.full, tt.width), tt.full); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGraphemeIndexes(t *testing.T) {
+	tests := []struct {
+		name  string
+		str   string
+		runes []int
+		want  []int
+	}{
+		{name: "plain text", str: "abc", runes: []int{0, 2}, want: []int{0, 2}},
+		{name: "after a flag", str: flags, runes: []int{4, 5}, want: []int{2, 3}},
+		{name: "inside a flag", str: flags, runes: []int{0, 1}, want: []int{0}},
+		{name: "after a ZWJ emoji", str: family, runes: []int{5, 7}, want: []int{1, 3}},
+		{name: "combining marks", str: combining, runes: []int{2, 4, 5}, want: []int{1, 2}},
+		{name: "none", str: "abc", runes: nil, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := graphemeIndexes(tt.str, tt.runes); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHighlightMatches(t *testing.T) {
+	mark := lipgloss.NewStyle().Transform(func(s string) string { return "[" + s + "]" })
+	tests := []struct {
+		name      string
+		text      string
+		positions []int
+		want      string
+	}{
+		{name: "runs of matches", text: "abcd", positions: []int{0, 1, 3}, want: "[ab]c[d]"},
+		{name: "flag is one character", text: flags[:8] + "ab", positions: []int{0, 2}, want: "[" + flags[:8] + "]a[b]"},
+		{name: "past the end", text: "ab", positions: []int{1, 5}, want: "a[b]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := highlightMatches(tt.text, tt.positions, lipgloss.NewStyle(), mark); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,25 +1,41 @@
 package main
 
 import (
+	"context"
+	"crypto/sha1"
 	"fmt"
 	"image"
 	"image/color"
 	_ "image/gif" // These aren't used directly, but are required for image.Decode to work
 	_ "image/jpeg"
 	_ "image/png"
-	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
+	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/rivo/uniseg"
 	"golang.org/x/image/draw"
 
 	moji "github.com/Treyson-Grange/go-moji-ui"
 )
 
 const SPOTIFY_GREEN = "#1DB954"
+const ERROR_RED = "#E22134"
+const WARNING_YELLOW = "#F5A623"
 const UI_LIBRARY_SPACE = 7 // Space to subtract from total to get library space
 const CHARACTERS = 8       // Characters we have to account for when truncating
 const LIBRARY_SPACING = 10
+const BOX_PADDING = 2    // Cells boxStyle's padding takes out of its width
+const ROW_DECORATION = 9 // Cells of a library row that aren't name or artist: favorite, cursor, column gap and play marker
+const QUEUE_HEIGHT = 8   // Height of the queue pane, inside its border
+const QUEUE_ROWS = 5     // Queue entries shown at once, the rest are a scroll away
+
+const COVER_MAX_AGE = 30 * 24 * time.Hour // Covers not shown for this long are dropped from the cache
+const COVER_CACHE_BYTES = 50 << 20        // Beyond this, the least recently shown covers are dropped too
 
 var (
 	boxStyle = lipgloss.NewStyle().
@@ -30,22 +46,14 @@ var (
 	libraryStyle = lipgloss.NewStyle().
 			Border(lipgloss.NormalBorder()).
 			Padding(0).Align(lipgloss.Left)
+
+	playBackStyle = boxStyle.Padding(0, 1) // Three lines: what's playing, the progress bar and the status line
 )
 
 // =======================
 // === Text Formatting ===
 // =======================
 
-// Truncate a string to fit any width
-func truncate(str string, width int) string {
-	if len(str) > width {
-		if width > 5 {
-			return str[:width-5] + "..."
-		}
-	}
-	return str
-}
-
 // Wrap a string in brackets
 func bracketWrap(str string) string {
 	return fmt.Sprintf(" [ %s ] ", str)
@@ -94,21 +102,84 @@ func printImage(img image.Image) string {
 	return result.String()
 }
 
-// Simple fetch for an image given a URL
-func fetchImage(url string) (image.Image, error) {
-	resp, err := http.Get(url)
+// Get the path of a cover image in the cache, downloading it if we haven't seen it before.
+// A hit is touched, so pruneCovers keeps the covers still being shown.
+func cachedCover(ctx context.Context, url string) (string, error) {
+	path := cachePath(filepath.Join("covers", fmt.Sprintf("%x", sha1.Sum([]byte(url)))))
+	if _, err := os.Stat(path); err == nil {
+		now := time.Now()
+		os.Chtimes(path, now, now)
+		return path, nil
+	}
+	return path, downloadFile(ctx, url, path)
+}
+
+// Trim the cover cache: drop covers older than maxAge, then the oldest ones until it fits in maxBytes
+func pruneCovers(dir string, maxAge time.Duration, maxBytes int64, now time.Time) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return // Nothing cached yet
+	}
+	type cover struct {
+		path    string
+		size    int64
+		touched time.Time
+	}
+	var covers []cover
+	var total int64
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		covers = append(covers, cover{path: filepath.Join(dir, entry.Name()), size: info.Size(), touched: info.ModTime()})
+		total += info.Size()
+	}
+	sort.Slice(covers, func(i, j int) bool { return covers[i].touched.Before(covers[j].touched) })
+	removed := 0
+	for _, c := range covers {
+		if now.Sub(c.touched) < maxAge && total <= maxBytes {
+			break
+		}
+		if err := os.Remove(c.path); err != nil {
+			errorLogger.Println("Failed to prune cover: ", err)
+			continue
+		}
+		total -= c.size
+		removed++
+	}
+	if removed > 0 {
+		infoLogger.Printf("Pruned %d covers from the cache", removed)
+	}
+}
+
+// Get a command that trims the cover cache in the background
+func pruneCoversCmd() tea.Cmd {
+	return func() tea.Msg {
+		pruneCovers(cachePath("covers"), COVER_MAX_AGE, COVER_CACHE_BYTES, time.Now())
+		return nil
+	}
+}
+
+// Simple fetch for an image given a URL, going through the cover cache
+func fetchImage(ctx context.Context, url string) (image.Image, error) {
+	path, err := cachedCover(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
+	defer file.Close()
 
-	img, _, err := image.Decode(resp.Body)
+	img, _, err := image.Decode(file)
 	return img, err
 }
 
 // Handler for fetching an image, resizing it, and returning it as a string
-func makeNewImage(url string) string {
-	img, err := fetchImage(url)
+func makeNewImage(ctx context.Context, url string) string {
+	img, err := fetchImage(ctx, url)
 	if err != nil {
 		return "Error fetching image"
 	}
@@ -121,51 +192,182 @@ func makeNewImage(url string) string {
 // ===== UI Elements =====
 // =======================
 
+// screenLayout is where View puts each pane, shared with the mouse handling so clicks land on what was drawn.
+type screenLayout struct {
+	boxWidth      int // Width of the library, cover and queue panes
+	boxHeight     int // Height of the library pane
+	playBackWidth int
+	jukeboxHeight int
+}
+
+// Lay out the panes for a terminal size
+func newScreenLayout(width, height int) screenLayout {
+	boxHeight := height - UI_LIBRARY_SPACE
+	return screenLayout{
+		boxWidth:      width/2 - 2,
+		boxHeight:     boxHeight,
+		playBackWidth: width - 2,
+		jukeboxHeight: boxHeight - QUEUE_HEIGHT - 2,
+	}
+}
+
+// Screen column where the cover and queue panes start, right of the library and its borders
+func (l screenLayout) rightPaneLeft() int { return l.boxWidth + 2 }
+
+// Screen row where the queue pane starts, under the cover and its borders
+func (l screenLayout) queueTop() int { return l.jukeboxHeight + 2 }
+
+// Screen row of the first library row, under the border and the header line
+func (l screenLayout) libraryRowsTop() int { return 2 }
+
+// Screen row where the playback bar starts, under the library and its borders
+func (l screenLayout) playBackTop() int { return l.boxHeight + 2 }
+
+// Screen row of the progress bar, under the playback bar's border and its text line
+func (l screenLayout) progressBarRow() int { return l.playBackTop() + 2 }
+
+// Screen column where the progress bar starts, inside the playback bar's border and padding
+func (l screenLayout) progressBarLeft() int { return 1 + BOX_PADDING/2 }
+
+// Width of the progress bar, the whole playback bar inside its padding
+func (l screenLayout) progressBarWidth() int { return l.playBackWidth - BOX_PADDING }
+
 // Get the UI elements for display
-func getUiElements(m Model, boxWidth int) (string, string, string, string) {
-	return getLibText(m, boxWidth), getPlayBack(m), m.image, getVisualQueue(m, boxWidth)
+func getUiElements(m Model, boxWidth, playBackWidth int) (string, string, string, string) {
+	return getLibText(m, boxWidth), getPlayBack(m, playBackWidth), m.image, getVisualQueue(m, boxWidth)
 }
 
 // Generate the library text for display
 func getLibText(m Model, boxWidth int) string {
 	libText := ""
+	if m.showMessages {
+		return getMessagesText(m, boxWidth, m.height-LIBRARY_SPACING)
+	}
+	if m.artist != nil {
+		return getArtistText(m, boxWidth)
+	}
+	if m.show != nil {
+		return getShowText(m, boxWidth)
+	}
 	if m.libraryList == nil {
 		return "Loading Library Data..."
 	}
-	libText += fmt.Sprintf("Page %d of %d", m.offset/(m.height-UI_LIBRARY_SPACE-len(m.favorites))+1, m.apiTotal/(m.height-UI_LIBRARY_SPACE)+1)
+	libText += fmt.Sprintf("Page %d of %d", m.pager.Page()+1, m.pager.Pages())
+	if m.tagFilter != "" {
+		libText += "  #" + m.tagFilter
+	}
+	if m.search != "" {
+		libText += "  /" + m.search
+	} else if m.listDetail != "favorites" {
+		libText += "  " + sortLabel(m.listDetail, m.settings.SortMode(m.listDetail))
+	}
 	if m.loading {
 		libText += "  Loading..."
 	}
-	libText += "\n"
-	if m.libraryList != nil {
-		for i, item := range m.libraryList {
-			if i == m.cursor {
-				item = LibraryItem{
-					name:     lipgloss.NewStyle().Foreground(lipgloss.Color(SPOTIFY_GREEN)).Render("> " + truncate(item.name, boxWidth-len(item.artist)-CHARACTERS)),
-					artist:   item.artist,
-					uri:      item.uri,
-					favorite: item.favorite,
-				}
-			} else {
-				item = LibraryItem{
-					name:     "  " + truncate(item.name, boxWidth-len(item.artist)-CHARACTERS),
-					artist:   item.artist,
-					uri:      item.uri,
-					favorite: item.favorite,
-				}
-			}
-			play := map[bool]string{true: " 🔊", false: ""}[m.state.Context.URI == item.uri]
-			favorite := map[bool]string{true: "♥ ", false: "  "}[item.favorite]
-			libText += fmt.Sprintf("%s%s - %s%s\n", favorite, moji.FilterEmojisBySize(item.name, 2), item.artist, play)
+	if m.offline && m.listDetail != "favorites" {
+		libText += "  Offline, " + syncedAgo(m.libraryCache.SyncedAt(), time.Now())
+	}
+	if m.exportStatus != "" {
+		libText += "  " + m.exportStatus
+	}
+	libText = truncate(libText, boxWidth) + "\n" // One line, or mouse clicks land on the wrong row
+	if len(m.libraryList) == 0 && m.search != "" {
+		libText += "  No matches\n"
+	}
+	artistWidth := libraryArtistWidth(m.libraryList, boxWidth)
+	for i, item := range m.libraryList {
+		libText += getLibraryRow(m, i, item, boxWidth, artistWidth)
+	}
+	return libText + getPromptText(m)
+}
+
+// Generate an artist page for display in place of the library
+func getArtistText(m Model, boxWidth int) string {
+	page := m.artist
+	libText := page.header()
+	if m.loading {
+		libText += "  Loading..."
+	}
+	libText = truncate(libText, boxWidth) + "\n" // One line, or mouse clicks land on the wrong row
+	section := ""
+	artistWidth := libraryArtistWidth(m.libraryList, boxWidth)
+	for i, item := range m.libraryList {
+		if next := page.section(i); next != section {
+			section = next
+			libText += lipgloss.NewStyle().Bold(true).Render(truncate(section, boxWidth)) + "\n"
 		}
+		libText += getLibraryRow(m, i, item, boxWidth, artistWidth)
 	}
-	return libText
+	return libText + getPromptText(m)
 }
 
-// Generate the playback text for display
-func getPlayBack(m Model) string {
-	if m.state.Item.Artists == nil {
-		return "No Playback Data. Please start a playback session on your device"
+// Generate a show's episode list for display in place of the library
+func getShowText(m Model, boxWidth int) string {
+	libText := m.show.header()
+	if m.loading {
+		libText += "  Loading..."
+	}
+	libText = truncate(libText, boxWidth) + "\n" // One line, or mouse clicks land on the wrong row
+	artistWidth := libraryArtistWidth(m.libraryList, boxWidth)
+	for i, item := range m.libraryList {
+		libText += getLibraryRow(m, i, item, boxWidth, artistWidth)
+	}
+	return libText + getPromptText(m)
+}
+
+// Get the width of the artist column for a page of library rows
+func libraryArtistWidth(items []LibraryItem, boxWidth int) int {
+	artists := make([]string, 0, len(items))
+	for _, item := range items {
+		artists = append(artists, item.artist)
+	}
+	return columnWidth(artists, boxWidth)
+}
+
+// Generate one row of the library in aligned name and artist columns, with the cursor, favorite marker and search matches
+func getLibraryRow(m Model, i int, item LibraryItem, boxWidth, artistWidth int) string {
+	matchStyle := lipgloss.NewStyle().Foreground(lipgloss.Color(SPOTIFY_GREEN)).Bold(true).Underline(true)
+	nameWidth := boxWidth - artistWidth - ROW_DECORATION
+	if item.group != "" {
+		prefix := "[" + item.group + "] "
+		item.name = prefix + item.name
+		shifted := make([]int, len(item.nameMatches)) // Copied, the matches belong to the model
+		for j, pos := range item.nameMatches {
+			shifted[j] = pos + uniseg.GraphemeClusterCount(prefix)
+		}
+		item.nameMatches = shifted
+	}
+	name := truncate(item.name, nameWidth)
+	artist := truncate(item.artist, artistWidth)
+
+	base, cursor := lipgloss.NewStyle(), "  "
+	if i == m.cursor {
+		base = lipgloss.NewStyle().Foreground(lipgloss.Color(SPOTIFY_GREEN))
+		cursor = base.Render("> ")
+	}
+	name = moji.FilterEmojisBySize(highlightMatches(name, visibleMatches(item.nameMatches, name, item.name), base, matchStyle), 2)
+	artist = highlightMatches(artist, visibleMatches(item.artistMatches, artist, item.artist), lipgloss.NewStyle(), matchStyle)
+	play := map[bool]string{true: " 🔊", false: ""}[m.state.Context.URI == item.uri || m.state.Item.URI == item.uri]
+	favorite := map[bool]string{true: "♥ ", false: "  "}[item.favorite]
+	if item.slot > 0 {
+		favorite = fmt.Sprintf("♥%d", item.slot)
+	}
+	return favorite + cursor + pad(name, nameWidth) + COLUMN_GAP + pad(artist, artistWidth) + play + "\n"
+}
+
+// Generate the prompt line shown under the library, if a prompt is open
+func getPromptText(m Model) string {
+	if m.prompt.kind == "" {
+		return ""
+	}
+	return "\n" + lipgloss.NewStyle().Foreground(lipgloss.Color(SPOTIFY_GREEN)).Render(m.prompt.kind+": ") + m.prompt.value + "█"
+}
+
+// Generate the playback text for display: what's playing, the progress bar and the status line.
+// A title too long for the bar scrolls.
+func getPlayBack(m Model, width int) string {
+	if m.state.Item.URI == "" {
+		return "No Playback Data. Please start a playback session on your device\n\n" + getStatusLine(m, width-BOX_PADDING)
 	}
 	status := "▶ "
 	if m.state.IsPlaying {
@@ -176,31 +378,135 @@ func getPlayBack(m Model) string {
 		shuffle = "Shuffle"
 	}
 
-	progress := msToMinSec(m.progressMs) + " / " + msToMinSec(m.state.Item.DurationMs)
+	progress := msToMinSec(m.progressMs()) + " / " + msToMinSec(m.state.Item.DurationMs)
 	statusRendered := lipgloss.NewStyle().Foreground(lipgloss.Color(SPOTIFY_GREEN)).Render(status)
 
-	return bracketWrap(m.state.Item.Name + " | " + m.state.Item.Artists[0].Name) +
-		bracketWrap(statusRendered) +
+	display := m.state.display()
+	byline := " | " + display.FirstArtist()
+	if display.Kind == "episode" && display.Subtitle != "" {
+		byline += " · " + display.Subtitle
+	}
+
+	controls := bracketWrap(statusRendered) +
 		bracketWrap(progress) +
 		bracketWrap(shuffle)
+	titleWidth := width - BOX_PADDING - textWidth(controls) - textWidth(bracketWrap(""))
+	return bracketWrap(marquee(display.Title+byline, titleWidth, m.marquee)) + controls + "\n" +
+		getProgressBar(m, width-BOX_PADDING) + "\n" +
+		getStatusLine(m, width-BOX_PADDING)
+}
 
+// Generate a progress bar for the playing item, with the played part in green. Clicking it seeks.
+func getProgressBar(m Model, width int) string {
+	if m.state.Item.DurationMs <= 0 || width <= 0 {
+		return ""
+	}
+	played := max(0, min(width, width*m.progressMs()/m.state.Item.DurationMs))
+	return lipgloss.NewStyle().Foreground(lipgloss.Color(SPOTIFY_GREEN)).Render(strings.Repeat("━", played)) +
+		lipgloss.NewStyle().Faint(true).Render(strings.Repeat("─", width-played))
+}
+
+// Get the first queue entry shown, the queue offset kept within the queue
+func (m Model) queueStart() int {
+	return max(0, min(m.queueOffset, len(m.queue.Queue)-QUEUE_ROWS))
 }
 
-// Generate the visual queue for display
+// Generate the visual queue for display, in aligned name, artist and duration columns, scrolled to the queue offset
 func getVisualQueue(m Model, boxWidth int) string {
 	queue := "Queue:\n"
-	queueLen := len(m.queue.Queue)
-	SEP := " - "
-	for i, item := range m.queue.Queue {
-		nameLen := len(item.Name)
-		artistLen := len(item.Artists[0].Name)
-		if len(SEP)+nameLen+artistLen > boxWidth {
-			item.Name = truncate(item.Name, boxWidth-3-artistLen)
+	rowWidth := boxWidth - BOX_PADDING
+	offset := m.queueStart()
+	var items []DisplayItem
+	var artists, durations []string
+	for _, item := range m.queue.Queue[offset:min(len(m.queue.Queue), offset+QUEUE_ROWS)] {
+		display := item.display()
+		duration := ""
+		if display.DurationMs > 0 {
+			duration = msToMinSec(display.DurationMs)
 		}
-		queue += fmt.Sprintf("%s - %s", item.Name, item.Artists[0].Name)
-		if i < queueLen-1 {
+		items = append(items, display)
+		artists = append(artists, display.FirstArtist())
+		durations = append(durations, duration)
+	}
+	artistWidth := columnWidth(artists, rowWidth)
+	durationWidth := columnWidth(durations, rowWidth)
+	nameWidth := rowWidth - artistWidth - durationWidth - 2*len(COLUMN_GAP)
+	for i, display := range items {
+		queue += pad(truncate(display.Title, nameWidth), nameWidth) + COLUMN_GAP +
+			pad(truncate(artists[i], artistWidth), artistWidth) + COLUMN_GAP +
+			padLeft(durations[i], durationWidth)
+		if i < len(items)-1 {
 			queue += "\n"
 		}
 	}
 	return queue
 }
+
+// Generate the lyrics pane text for display, keeping the current line in the middle
+func getLyricsText(m Model, boxWidth, height int) string {
+	if uriKind(m.state.Item.URI) == "episode" {
+		return "No lyrics for episodes"
+	}
+	if m.lyrics.URI != m.state.Item.URI {
+		return "Loading Lyrics..."
+	}
+	if len(m.lyrics.Lines) == 0 {
+		return "No lyrics found"
+	}
+
+	current := m.lyrics.currentLine(m.progressMs())
+	start := max(0, min(current-height/2, len(m.lyrics.Lines)-height))
+	end := min(len(m.lyrics.Lines), start+height)
+
+	lines := make([]string, 0, height)
+	for i := start; i < end; i++ {
+		text := truncate(m.lyrics.Lines[i].Text, boxWidth-CHARACTERS)
+		if i == current {
+			text = lipgloss.NewStyle().Foreground(lipgloss.Color(SPOTIFY_GREEN)).Bold(true).Render(text)
+		}
+		lines = append(lines, text)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Generate the stats screen text for display
+func getStatsText(m Model, boxWidth int) string {
+	if m.history == nil {
+		return "Listening history is unavailable. Is another JukeTUI running?"
+	}
+	stats := m.stats
+	if stats.Period == "" {
+		return "Loading Stats..."
+	}
+
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("Listening stats for the past %s  (%s/%s to change)\n\n", stats.Period, keybinds["Previous Page"], keybinds["Next Page"]))
+
+	hours := stats.ListenedMs / (1000 * 60 * 60)
+	minutes := stats.ListenedMs / (1000 * 60) % 60
+	text.WriteString(fmt.Sprintf("Total listening time: %dh %02dm\n", hours, minutes))
+	skipRate := 0
+	if stats.Plays > 0 {
+		skipRate = stats.Skips * 100 / stats.Plays
+	}
+	text.WriteString(fmt.Sprintf("Plays: %d  Skips: %d  Skip rate: %d%%\n", stats.Plays, stats.Skips, skipRate))
+
+	for _, section := range []struct {
+		title   string
+		entries []statsEntry
+	}{
+		{"Top Tracks", stats.TopTracks},
+		{"Top Artists", stats.TopArtists},
+		{"Top Albums", stats.TopAlbums},
+	} {
+		text.WriteString("\n" + lipgloss.NewStyle().Foreground(lipgloss.Color(SPOTIFY_GREEN)).Render(section.title) + "\n")
+		if len(section.entries) == 0 {
+			text.WriteString("  Nothing yet\n")
+		}
+		for i, entry := range section.entries {
+			plays := fmt.Sprintf(" (%d)", entry.Plays)
+			text.WriteString(fmt.Sprintf("  %d. %s%s\n", i+1, truncate(entry.Name, boxWidth-len(plays)-CHARACTERS), plays))
+		}
+	}
+	return text.String()
+}
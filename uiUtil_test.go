@@ -0,0 +1,99 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+func TestPruneCovers(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	type cover struct {
+		name string
+		size int
+		age  time.Duration
+	}
+	tests := []struct {
+		name     string
+		covers   []cover
+		maxAge   time.Duration
+		maxBytes int64
+		want     []string // Covers left, sorted by name
+	}{
+		{"nothing to prune", []cover{{"a", 10, day}, {"b", 10, 2 * day}}, 30 * day, 100, []string{"a", "b"}},
+		{"too old", []cover{{"a", 10, day}, {"b", 10, 31 * day}}, 30 * day, 100, []string{"a"}},
+		{"over size drops least recently shown", []cover{{"a", 40, day}, {"b", 40, 3 * day}, {"c", 40, 2 * day}}, 30 * day, 100, []string{"a", "c"}},
+		{"old and over size", []cover{{"a", 60, day}, {"b", 60, 2 * day}, {"c", 10, 40 * day}}, 30 * day, 100, []string{"a"}},
+		{"everything over size", []cover{{"a", 200, day}}, 30 * day, 100, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, c := range tt.covers {
+				path := filepath.Join(dir, c.name)
+				if err := os.WriteFile(path, make([]byte, c.size), 0644); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Chtimes(path, now.Add(-c.age), now.Add(-c.age)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			pruneCovers(dir, tt.maxAge, tt.maxBytes, now)
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var left []string
+			for _, entry := range entries {
+				left = append(left, entry.Name())
+			}
+			if !slices.Equal(left, tt.want) {
+				t.Errorf("left %v, want %v", left, tt.want)
+			}
+		})
+	}
+}
+
+func TestPruneCoversMissingDir(t *testing.T) {
+	pruneCovers(filepath.Join(t.TempDir(), "covers"), time.Hour, 100, time.Now()) // Must not fail before anything is cached
+}
+
+func TestPageHeadersFitPane(t *testing.T) {
+	const boxWidth = 24
+	artist := &ArtistPage{Related: []SpotifyArtist{{Name: "Thom Yorke"}}}
+	artist.Artist.Name = "Godspeed You! Black Emperor"
+	artist.Artist.Followers.Total = 1234567
+	artist.Artist.Genres = []string{"post-rock", "experimental", "drone"}
+	rows := []LibraryItem{{name: "Play artist", artist: "Artist"}, {name: "Thom Yorke", artist: "Artist"}}
+
+	tests := []struct {
+		name     string
+		model    Model
+		render   func(Model, int) string
+		lines    int // Header, plus headings and rows
+		clickRow int // The row drawn on the last line
+	}{
+		{"artist page", Model{artist: artist, libraryList: rows, loading: true}, getArtistText, 4, 1},
+		{"show page", Model{show: &ShowPage{Show: LibraryItem{name: "The Longest Running Podcast Title", artist: "Some Network"}}, libraryList: rows}, getShowText, 3, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := strings.Split(strings.TrimSuffix(tt.render(tt.model, boxWidth), "\n"), "\n")
+			if len(lines) != tt.lines {
+				t.Fatalf("%d lines, want %d:\n%s", len(lines), tt.lines, strings.Join(lines, "\n"))
+			}
+			if width := lipgloss.Width(lines[0]); width > boxWidth {
+				t.Errorf("header %q is %d wide, pane is %d", lines[0], width, boxWidth)
+			}
+			if row, ok := tt.model.libraryRowAt(tt.lines - 2); !ok || row != tt.clickRow {
+				t.Errorf("last line is row %d (%v), want %d", row, ok, tt.clickRow)
+			}
+		})
+	}
+}
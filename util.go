@@ -1,33 +1,19 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"log"
 	"os"
-	"time"
+	"path/filepath"
 
-	tea "github.com/charmbracelet/bubbletea"
+	"github.com/joho/godotenv"
 )
 
 // ===========================================
 // ===== util.go | General Program Utils =====
 // ===========================================
 
-// Schedule the next fetch of the playback state.
-func scheduleNextFetch(d time.Duration) tea.Cmd {
-	return func() tea.Msg {
-		time.Sleep(d)
-		return playbackMsg{}
-	}
-}
-
-// Schedule the next increment of the progress bar.
-func scheduleProgressInc(d time.Duration) tea.Cmd {
-	return func() tea.Msg {
-		time.Sleep(d)
-		return progressMsg{}
-	}
-}
-
 // Check if the user has passed in any arguments
 func checkArguments() {
 	if len(os.Args) > 1 {
@@ -39,8 +25,30 @@ func checkArguments() {
 					"Select",
 					"Shuffle",
 					"Favorites",
+					"Favorite Playing",
+					"Switch Library",
+					"Edit Tags",
+					"Edit Group",
+					"Filter Tag",
+					"Search",
+					"Artist",
+					"Playing Artist",
+					"Queue Artist",
+					"Follow",
+					"Back",
+					"Sort",
+					"Assign Slot",
+					"Move Up",
+					"Move Down",
+					"Stats",
+					"Export",
+					"Lyrics",
+					"Messages",
 					"Next Page",
 					"Previous Page",
+					"First Page",
+					"Last Page",
+					"Jump To Page",
 					"Cursor Up",
 					"Cursor Down",
 					"Quit",
@@ -59,6 +67,14 @@ func checkArguments() {
 	}
 }
 
+// Load the .env file, if there is one. Everything in it can also come from the environment,
+// and `juketui status` runs from status bars in any directory, so a missing file is fine.
+func loadEnv() {
+	if err := godotenv.Load(".env"); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatalf("Error loading .env file: %v", err)
+	}
+}
+
 // Query an environment variable, returning a default value if it is not set
 func queryEnv(envKey, defaultValue string) string {
 	if v := os.Getenv(envKey); v != "" {
@@ -67,18 +83,110 @@ func queryEnv(envKey, defaultValue string) string {
 	return defaultValue
 }
 
+// Get the path of a file inside the JukeTUI data directory, creating the directory if needed.
+// Follows the XDG base directory spec, falling back to ~/.local/share/juketui.
+func dataPath(name string) string {
+	dir := os.Getenv("XDG_DATA_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			home = "."
+		}
+		dir = filepath.Join(home, ".local", "share")
+	}
+	dir = filepath.Join(dir, "juketui")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		errorLogger.Printf("Failed to create data directory %s: %v", dir, err)
+	}
+	return filepath.Join(dir, name)
+}
+
+// writeFileAtomic writes a file through a temporary file and a rename, so readers never see half a write.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Flush a directory's entries to disk, so a rename inside it survives a crash
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil && !errors.Is(err, os.ErrInvalid) { // Not every platform can sync a directory
+		return err
+	}
+	return nil
+}
+
+// Get the path of a file inside the JukeTUI cache directory, creating any directories needed.
+// Follows the XDG base directory spec, falling back to ~/.cache/juketui.
+func cachePath(name string) string {
+	dir := os.Getenv("XDG_CACHE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			home = "."
+		}
+		dir = filepath.Join(home, ".cache")
+	}
+	path := filepath.Join(dir, "juketui", name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		errorLogger.Printf("Failed to create cache directory %s: %v", filepath.Dir(path), err)
+	}
+	return path
+}
+
 // Set the keybinds for the application
 func setKeybinds() {
 	keybinds = map[string]string{
-		"Quit":          queryEnv("QUIT", "q"),
-		"Play/Pause":    queryEnv("PLAYPAUSE", "p"),
-		"Skip":          queryEnv("SKIP", "n"),
-		"Shuffle":       queryEnv("SHUFFLE", "s"),
-		"Favorites":     queryEnv("FAVORITES", "f"),
-		"Cursor Up":     "up",
-		"Cursor Down":   "down",
-		"Next Page":     "right",
-		"Previous Page": "left",
-		"Select":        "enter",
+		"Quit":             queryEnv("QUIT", "q"),
+		"Play/Pause":       queryEnv("PLAYPAUSE", "p"),
+		"Skip":             queryEnv("SKIP", "n"),
+		"Shuffle":          queryEnv("SHUFFLE", "s"),
+		"Favorites":        queryEnv("FAVORITES", "f"),
+		"Stats":            queryEnv("STATS", "t"),
+		"Export":           queryEnv("EXPORT", "x"),
+		"Lyrics":           queryEnv("LYRICS", "l"),
+		"Messages":         queryEnv("MESSAGES", "m"),
+		"Edit Tags":        queryEnv("EDIT_TAGS", "e"),
+		"Edit Group":       queryEnv("EDIT_GROUP", "g"),
+		"Filter Tag":       queryEnv("FILTER_TAG", "#"),
+		"Assign Slot":      queryEnv("ASSIGN_SLOT", "b"),
+		"Favorite Playing": queryEnv("FAVORITE_PLAYING", "ctrl+f"),
+		"Switch Library":   queryEnv("SWITCH_LIBRARY", "tab"),
+		"Move Up":          "shift+up",
+		"Move Down":        "shift+down",
+		"Cursor Up":        "up",
+		"Cursor Down":      "down",
+		"Next Page":        "right",
+		"Previous Page":    "left",
+		"First Page":       "home",
+		"Last Page":        "end",
+		"Jump To Page":     queryEnv("JUMP_TO_PAGE", "ctrl+g"),
+		"Search":           queryEnv("SEARCH", "/"),
+		"Sort":             queryEnv("SORT", "o"),
+		"Artist":           queryEnv("ARTIST", "a"),
+		"Playing Artist":   queryEnv("PLAYING_ARTIST", "ctrl+a"),
+		"Queue Artist":     queryEnv("QUEUE_ARTIST", "ctrl+n"),
+		"Follow":           queryEnv("FOLLOW", "w"),
+		"Back":             "esc",
+		"Select":           "enter",
 	}
 }